@@ -12,6 +12,7 @@ import (
 	"social-media-app/internal/repository"
 	"social-media-app/internal/service"
 	"social-media-app/internal/storage"
+	"social-media-app/internal/utils"
 	"social-media-app/internal/websocket"
 
 	"github.com/gin-gonic/gin"
@@ -40,30 +41,66 @@ func main() {
 		log.Fatal("Failed to connect to MinIO:", err)
 	}
 
-	// Initialize WebSocket hub
-	wsHub := websocket.NewHub()
-	go wsHub.Run()
+	// Load JWT signing and verification keys
+	jwtKeys, err := utils.LoadKeyManager(&cfg.JWT)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	postRepo := repository.NewPostRepository(db)
 	messageRepo := repository.NewMessageRepository(db)
+	followRepo := repository.NewFollowRepository(db)
+	reactionRepo := repository.NewReactionRepository(db)
+	conversationRepo := repository.NewConversationRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+
+	// Index posts and messages stored before search existed
+	if err := searchRepo.BackfillSearch(); err != nil {
+		log.Printf("Error backfilling search documents: %v", err)
+	}
 
 	// Initialize services
-	redisService := service.NewRedisService(redisClient)
-	userService := service.NewUserService(userRepo, cfg.JWT.Secret)
-	postService := service.NewPostService(postRepo, redisService)
-	messageService := service.NewMessageService(messageRepo, redisService)
+	redisService := service.NewRedisService(redisClient, cfg.Cache)
+	go redisService.RunCacheInvalidation()
+	sessionService := service.NewSessionService(redisService, userRepo, jwtKeys, cfg.JWT)
+	userService := service.NewUserService(userRepo, sessionService)
+	reactionService := service.NewReactionService(reactionRepo, postRepo, messageRepo, redisService)
+	go reactionService.Run()
+	notificationService := service.NewNotificationService(notificationRepo)
+	entityService := service.NewEntityService(userRepo, redisService)
+	feedService := service.NewFeedService(postRepo, followRepo, userRepo, redisService, reactionService, cfg.Feed)
+	followService := service.NewFollowService(followRepo, userRepo, feedService, notificationService)
 	uploadService := service.NewUploadService(minioClient, cfg.MinIO.Bucket)
+	postService := service.NewPostService(postRepo, redisService, feedService, uploadService, reactionService, entityService, notificationService)
+	messageService := service.NewMessageService(messageRepo, postRepo, redisService, reactionService, notificationService, entityService)
+	presenceService := service.NewPresenceService(redisService, postRepo, userRepo)
+	conversationService := service.NewConversationService(conversationRepo, userRepo)
+	searchService := service.NewSearchService(searchRepo, postRepo, messageRepo, userRepo, reactionService)
 
 	// Initialize rate limiter
 	rateLimiter := middleware.NewRateLimiter(redisClient)
 
+	// Initialize WebSocket hub (relayed across instances via Redis pub/sub)
+	wsHub := websocket.NewHub(redisService, messageService, middleware.TokenAuthenticator(jwtKeys, sessionService), rateLimiter.AllowMessage, cfg.WebSocket)
+	go wsHub.Run()
+	notificationService.SetNotifier(wsHub)
+
 	// Initialize handlers
-	userHandler := handler.NewUserHandler(userService)
-	postHandler := handler.NewPostHandler(postService)
+	userHandler := handler.NewUserHandler(userService, sessionService)
+	postHandler := handler.NewPostHandler(postService, wsHub)
 	messageHandler := handler.NewMessageHandler(messageService, wsHub)
 	uploadHandler := handler.NewUploadHandler(uploadService)
+	followHandler := handler.NewFollowHandler(followService)
+	feedHandler := handler.NewFeedHandler(feedService)
+	reactionHandler := handler.NewReactionHandler(reactionService, wsHub)
+	presenceHandler := handler.NewPresenceHandler(presenceService)
+	conversationHandler := handler.NewConversationHandler(conversationService, wsHub)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	tagHandler := handler.NewTagHandler(postService, entityService)
+	searchHandler := handler.NewSearchHandler(searchService)
 
 	// Setup Gin router
 	r := gin.Default()
@@ -91,11 +128,17 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public JWT verification keys for other services
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, jwtKeys.JWKS())
+	})
+
 	// Metrics endpoint
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	// WebSocket endpoint
-	r.GET("/ws", middleware.AuthMiddleware(cfg.JWT.Secret), wsHub.HandleWebSocket)
+	// WebSocket endpoint (authenticates via query token, subprotocol or auth frame)
+	r.GET("/ws", wsHub.HandleWebSocket)
 
 	// API routes
 	api := r.Group("/api/v1")
@@ -103,22 +146,61 @@ func main() {
 		// Public routes (no auth required)
 		api.POST("/users/register", userHandler.Register)
 		api.POST("/users/login", rateLimiter.LoginRateLimit(), userHandler.Login)
+		api.POST("/users/refresh", userHandler.Refresh)
 		api.GET("/posts", postHandler.GetPosts)
 		api.GET("/posts/:id", postHandler.GetPost)
 		api.GET("/posts/:id/messages", messageHandler.GetMessages)
+		api.GET("/posts/:id/presence", presenceHandler.GetPostPresence)
+		api.GET("/tags/trending", tagHandler.GetTrendingHashtags)
+		api.GET("/tags/:tag/posts", tagHandler.GetTagPosts)
+		api.GET("/search", searchHandler.Search)
 
 		// Protected routes (auth required)
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+		protected.Use(middleware.AuthMiddleware(jwtKeys, sessionService))
 		{
 			// User routes
 			protected.GET("/users/profile", userHandler.GetProfile)
+			protected.POST("/users/logout", userHandler.Logout)
+			protected.POST("/users/logout-all", userHandler.LogoutAll)
+
+			// Follow routes
+			protected.POST("/users/:id/follow", followHandler.Follow)
+			protected.DELETE("/users/:id/follow", followHandler.Unfollow)
+
+			// Feed routes
+			protected.GET("/feed/home", feedHandler.GetHomeFeed)
 
 			// Post routes
 			protected.POST("/posts", rateLimiter.PostCreationRateLimit(), postHandler.CreatePost)
+			protected.PUT("/posts/:id", postHandler.UpdatePost)
+			protected.DELETE("/posts/:id", postHandler.DeletePost)
 
 			// Message routes
 			protected.POST("/posts/:id/messages", rateLimiter.MessageRateLimit(), messageHandler.CreateMessage)
+			protected.PUT("/posts/:id/messages/:messageId", messageHandler.EditMessage)
+			protected.DELETE("/posts/:id/messages/:messageId", messageHandler.DeleteMessage)
+
+			// Reaction routes
+			protected.PUT("/posts/:id/reactions/:kind", reactionHandler.AddPostReaction)
+			protected.DELETE("/posts/:id/reactions/:kind", reactionHandler.RemovePostReaction)
+			protected.PUT("/posts/:id/messages/:messageId/reactions/:kind", reactionHandler.AddMessageReaction)
+			protected.DELETE("/posts/:id/messages/:messageId/reactions/:kind", reactionHandler.RemoveMessageReaction)
+
+			// Conversation routes
+			protected.POST("/conversations", conversationHandler.CreateConversation)
+			protected.GET("/conversations", conversationHandler.GetConversations)
+			protected.GET("/conversations/unread", conversationHandler.GetUnreadCount)
+			protected.GET("/conversations/:id", conversationHandler.GetConversation)
+			protected.GET("/conversations/:id/messages", conversationHandler.GetMessages)
+			protected.POST("/conversations/:id/messages", rateLimiter.MessageRateLimit(), conversationHandler.SendMessage)
+			protected.POST("/conversations/:id/read", conversationHandler.MarkRead)
+
+			// Notification routes
+			protected.GET("/notifications", notificationHandler.GetNotifications)
+			protected.GET("/notifications/unread", notificationHandler.GetUnreadCount)
+			protected.POST("/notifications/read", notificationHandler.MarkAllRead)
+			protected.POST("/notifications/:id/read", notificationHandler.MarkRead)
 
 			// Upload routes
 			protected.POST("/upload/image", uploadHandler.UploadImage)
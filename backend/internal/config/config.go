@@ -3,14 +3,19 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Port     string
-	Database DatabaseConfig
-	Redis    RedisConfig
-	MinIO    MinIOConfig
-	JWT      JWTConfig
+	Port      string
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	MinIO     MinIOConfig
+	JWT       JWTConfig
+	Feed      FeedConfig
+	WebSocket WebSocketConfig
+	Cache     CacheConfig
 }
 
 type DatabaseConfig struct {
@@ -34,12 +39,90 @@ type MinIOConfig struct {
 }
 
 type JWTConfig struct {
+	// Secret is only used for HS256 when no signing key file is configured
 	Secret string
+	// SigningKeyFile is a PEM RSA or Ed25519 private key used to sign new tokens
+	SigningKeyFile string
+	// VerificationKeyFiles are PEM keys of previous signing keys still accepted during rotation
+	VerificationKeyFiles []string
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+}
+
+type FeedConfig struct {
+	// Authors with at least this many followers are merged into timelines at read time
+	FanOutThreshold int
+	// Maximum number of post IDs kept in each home timeline
+	TimelineSize int
+}
+
+type WebSocketConfig struct {
+	// PingInterval is how often the server pings; must be shorter than PongWait
+	PingInterval time.Duration
+	// PongWait is how long a connection may stay silent before it is considered dead
+	PongWait time.Duration
+	// WriteWait bounds each write to the peer
+	WriteWait time.Duration
+	// AuthTimeout is how long a client has to send an auth frame
+	AuthTimeout time.Duration
+	// MaxMessageSize is the largest frame accepted from a client, in bytes
+	MaxMessageSize int64
+	// SendBufferSize is how many outbound messages are queued per client
+	SendBufferSize int
+	// SlowConsumerPolicy is what happens when a client's queue is full:
+	// "drop_oldest", "drop_newest" or "disconnect"
+	SlowConsumerPolicy string
+}
+
+type CacheConfig struct {
+	// EarlyRefreshBeta scales probabilistic early refresh (XFetch); higher
+	// refreshes earlier, 0 disables it
+	EarlyRefreshBeta float64
+	// Lock coalesces cache misses across instances with a Redis lock, so only
+	// one instance loads a missing entry while the others wait for it
+	Lock bool
+	// LockTTL bounds how long a loader may hold the lock
+	LockTTL time.Duration
+	// LockWait is how long other instances wait for the entry before loading it themselves
+	LockWait time.Duration
+	// L1Size is how many entries each instance keeps in memory in front of Redis; 0 disables it
+	L1Size int
+	// L1TTL bounds how long an in-memory entry is served, and so how stale it
+	// can get if an invalidation message is lost
+	L1TTL time.Duration
+	// KeyVersion prefixes every cache key; changing it (say after a model
+	// change) leaves all existing entries unread until they expire
+	KeyVersion string
+	// Codec encodes cached values: "json" or "msgpack"
+	Codec string
+	// How long each kind of entry is cached
+	PostTTL     time.Duration
+	FeedTTL     time.Duration
+	MessagesTTL time.Duration
 }
 
 func Load() *Config {
 	dbPort, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
 	redisPort, _ := strconv.Atoi(getEnv("REDIS_PORT", "6379"))
+	fanOutThreshold, _ := strconv.Atoi(getEnv("FEED_FANOUT_THRESHOLD", "10000"))
+	timelineSize, _ := strconv.Atoi(getEnv("FEED_TIMELINE_SIZE", "800"))
+	accessTokenTTL, _ := time.ParseDuration(getEnv("JWT_ACCESS_TTL", "15m"))
+	refreshTokenTTL, _ := time.ParseDuration(getEnv("JWT_REFRESH_TTL", "720h"))
+	wsPingInterval, _ := time.ParseDuration(getEnv("WS_PING_INTERVAL", "54s"))
+	wsPongWait, _ := time.ParseDuration(getEnv("WS_PONG_WAIT", "60s"))
+	wsWriteWait, _ := time.ParseDuration(getEnv("WS_WRITE_WAIT", "10s"))
+	wsAuthTimeout, _ := time.ParseDuration(getEnv("WS_AUTH_TIMEOUT", "10s"))
+	wsMaxMessageSize, _ := strconv.ParseInt(getEnv("WS_MAX_MESSAGE_SIZE", "4096"), 10, 64)
+	wsSendBufferSize, _ := strconv.Atoi(getEnv("WS_SEND_BUFFER_SIZE", "256"))
+	cacheEarlyRefreshBeta, _ := strconv.ParseFloat(getEnv("CACHE_EARLY_REFRESH_BETA", "1"), 64)
+	cacheLock, _ := strconv.ParseBool(getEnv("CACHE_LOCK", "false"))
+	cacheLockTTL, _ := time.ParseDuration(getEnv("CACHE_LOCK_TTL", "5s"))
+	cacheLockWait, _ := time.ParseDuration(getEnv("CACHE_LOCK_WAIT", "1s"))
+	cacheL1Size, _ := strconv.Atoi(getEnv("CACHE_L1_SIZE", "1000"))
+	cacheL1TTL, _ := time.ParseDuration(getEnv("CACHE_L1_TTL", "5s"))
+	cachePostTTL, _ := time.ParseDuration(getEnv("CACHE_POST_TTL", "10m"))
+	cacheFeedTTL, _ := time.ParseDuration(getEnv("CACHE_FEED_TTL", "5m"))
+	cacheMessagesTTL, _ := time.ParseDuration(getEnv("CACHE_MESSAGES_TTL", "2m"))
 
 	return &Config{
 		Port: getEnv("PORT", "8000"),
@@ -61,7 +144,37 @@ func Load() *Config {
 			Bucket:    getEnv("MINIO_BUCKET", "social-media-images"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
+			Secret:               getEnv("JWT_SECRET", ""),
+			SigningKeyFile:       getEnv("JWT_SIGNING_KEY_FILE", ""),
+			VerificationKeyFiles: getEnvList("JWT_VERIFICATION_KEY_FILES"),
+			AccessTokenTTL:       accessTokenTTL,
+			RefreshTokenTTL:      refreshTokenTTL,
+		},
+		Feed: FeedConfig{
+			FanOutThreshold: fanOutThreshold,
+			TimelineSize:    timelineSize,
+		},
+		WebSocket: WebSocketConfig{
+			PingInterval:       wsPingInterval,
+			PongWait:           wsPongWait,
+			WriteWait:          wsWriteWait,
+			AuthTimeout:        wsAuthTimeout,
+			MaxMessageSize:     wsMaxMessageSize,
+			SendBufferSize:     wsSendBufferSize,
+			SlowConsumerPolicy: getEnv("WS_SLOW_CONSUMER_POLICY", "disconnect"),
+		},
+		Cache: CacheConfig{
+			EarlyRefreshBeta: cacheEarlyRefreshBeta,
+			Lock:             cacheLock,
+			LockTTL:          cacheLockTTL,
+			LockWait:         cacheLockWait,
+			L1Size:           cacheL1Size,
+			L1TTL:            cacheL1TTL,
+			KeyVersion:       getEnv("CACHE_KEY_VERSION", "v1"),
+			Codec:            getEnv("CACHE_CODEC", "json"),
+			PostTTL:          cachePostTTL,
+			FeedTTL:          cacheFeedTTL,
+			MessagesTTL:      cacheMessagesTTL,
 		},
 	}
 }
@@ -72,3 +185,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList reads a comma-separated list, ignoring empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -22,8 +22,16 @@ func Connect(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Trigram matching backs the fuzzy username search
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return nil, fmt.Errorf("failed to enable pg_trgm: %w", err)
+	}
+
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&model.User{}, &model.Post{}, &model.Message{})
+	err = db.AutoMigrate(&model.User{}, &model.Post{}, &model.Message{}, &model.Follow{}, &model.Reaction{},
+		&model.Conversation{}, &model.ConversationParticipant{}, &model.DirectMessage{},
+		&model.Notification{}, &model.NotificationActor{},
+		&model.PostMention{}, &model.PostHashtag{}, &model.MessageMention{}, &model.MessageHashtag{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
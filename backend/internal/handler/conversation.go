@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"social-media-app/internal/model"
+	"social-media-app/internal/service"
+	"social-media-app/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ConversationHandler struct {
+	service *service.ConversationService
+	hub     *websocket.Hub
+}
+
+func NewConversationHandler(service *service.ConversationService, hub *websocket.Hub) *ConversationHandler {
+	return &ConversationHandler{
+		service: service,
+		hub:     hub,
+	}
+}
+
+func (h *ConversationHandler) CreateConversation(c *gin.Context) {
+	var req model.CreateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	conversation, created, err := h.service.CreateConversation(userID.(uuid.UUID), &req)
+	if err != nil {
+		respondConversationError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, conversation)
+}
+
+func (h *ConversationHandler) GetConversations(c *gin.Context) {
+	cursor, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, err := h.service.ListConversations(userID.(uuid.UUID), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ConversationHandler) GetConversation(c *gin.Context) {
+	conversationID, userID, ok := parseConversationRequest(c)
+	if !ok {
+		return
+	}
+
+	conversation, err := h.service.GetConversation(conversationID, userID)
+	if err != nil {
+		respondConversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+func (h *ConversationHandler) GetUnreadCount(c *gin.Context) {
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	count, err := h.service.CountUnread(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+func (h *ConversationHandler) GetMessages(c *gin.Context) {
+	conversationID, userID, ok := parseConversationRequest(c)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.service.GetMessages(conversationID, userID, cursor, limit)
+	if err != nil {
+		respondConversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ConversationHandler) SendMessage(c *gin.Context) {
+	conversationID, userID, ok := parseConversationRequest(c)
+	if !ok {
+		return
+	}
+
+	var req model.SendDirectMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, conversation, err := h.service.SendMessage(conversationID, userID, &req)
+	if err != nil {
+		respondConversationError(c, err)
+		return
+	}
+
+	// Deliver to every participant's connections, including the sender's other devices
+	if h.hub != nil {
+		h.hub.SendToUsers(c.Request.Context(), conversation.ParticipantIDs(), "direct_message", message)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message sent successfully",
+		"data":    message,
+	})
+}
+
+func (h *ConversationHandler) MarkRead(c *gin.Context) {
+	conversationID, userID, ok := parseConversationRequest(c)
+	if !ok {
+		return
+	}
+
+	var req model.MarkReadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	receipt, conversation, err := h.service.MarkRead(conversationID, userID, req.MessageID)
+	if err != nil {
+		respondConversationError(c, err)
+		return
+	}
+
+	// Nothing new was read
+	if receipt == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if h.hub != nil {
+		h.hub.SendToUsers(c.Request.Context(), conversation.ParticipantIDs(), "read_receipt", receipt)
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
+
+// parseConversationRequest extracts the conversation ID from the path and the authenticated user
+func parseConversationRequest(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return conversationID, userID.(uuid.UUID), true
+}
+
+func respondConversationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+	case errors.Is(err, service.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, service.ErrNoParticipants), errors.Is(err, service.ErrTooManyParticipants):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
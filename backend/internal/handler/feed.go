@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"social-media-app/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FeedHandler struct {
+	service *service.FeedService
+}
+
+func NewFeedHandler(service *service.FeedService) *FeedHandler {
+	return &FeedHandler{service: service}
+}
+
+func (h *FeedHandler) GetHomeFeed(c *gin.Context) {
+	cursor, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, err := h.service.GetHomeTimeline(c.Request.Context(), userID.(uuid.UUID), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"social-media-app/internal/model"
 	"social-media-app/internal/service"
 	"social-media-app/internal/websocket"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -43,15 +46,15 @@ func (h *MessageHandler) CreateMessage(c *gin.Context) {
 		return
 	}
 
-	message, err := h.service.CreateMessage(postID, senderID.(uuid.UUID), &req)
+	message, err := h.service.CreateMessage(c.Request.Context(), postID, senderID.(uuid.UUID), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondMessageError(c, err)
 		return
 	}
 
 	// Broadcast to WebSocket clients if hub is available
 	if h.hub != nil {
-		h.hub.BroadcastToPost(postID.String(), message)
+		h.hub.BroadcastToPost(c.Request.Context(), postID.String(), message)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -60,6 +63,99 @@ func (h *MessageHandler) CreateMessage(c *gin.Context) {
 	})
 }
 
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	postID, messageID, ok := parseMessagePath(c)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	message, err := h.service.EditMessage(c.Request.Context(), postID, messageID, userID.(uuid.UUID), &req)
+	if err != nil {
+		respondMessageError(c, err)
+		return
+	}
+
+	// Broadcast to WebSocket clients if hub is available
+	if h.hub != nil {
+		h.hub.BroadcastEvent(c.Request.Context(), "message_edited", postID.String(), message)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message updated successfully",
+		"data":    message,
+	})
+}
+
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	postID, messageID, ok := parseMessagePath(c)
+	if !ok {
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.service.DeleteMessage(c.Request.Context(), postID, messageID, userID.(uuid.UUID)); err != nil {
+		respondMessageError(c, err)
+		return
+	}
+
+	// Broadcast to WebSocket clients if hub is available
+	if h.hub != nil {
+		h.hub.BroadcastEvent(c.Request.Context(), "message_deleted", postID.String(), gin.H{"id": messageID, "post_id": postID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
+}
+
+// parseMessagePath reads the post and message IDs from the path, responding with 400 if they are invalid
+func parseMessagePath(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return postID, messageID, true
+}
+
+// respondMessageError maps message and ownership errors to HTTP statuses
+func respondMessageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPostNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	case errors.Is(err, service.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	case errors.Is(err, service.ErrForbidden), errors.Is(err, service.ErrEditWindowExpired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func (h *MessageHandler) GetMessages(c *gin.Context) {
 	postIDStr := c.Param("id")
 	postID, err := uuid.Parse(postIDStr)
@@ -68,13 +164,59 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
-	messages, err := h.service.GetMessagesByPostID(postID)
+	query, err := parseMessageQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"messages": messages,
-	})
+	page, err := h.service.GetMessagesPage(c.Request.Context(), postID, query)
+	if err != nil {
+		respondMessageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// parseMessageQuery reads the limit and the before/after/since window from the query string
+func parseMessageQuery(c *gin.Context) (*model.MessageQuery, error) {
+	query := &model.MessageQuery{}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		return nil, errors.New("Invalid limit")
+	}
+	query.Limit = limit
+
+	before, after, since := c.Query("before"), c.Query("after"), c.Query("since")
+
+	set := 0
+	for _, v := range []string{before, after, since} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("Only one of before, after and since may be set")
+	}
+
+	switch {
+	case before != "":
+		if query.Before, err = model.DecodeCursor(before); err != nil {
+			return nil, errors.New("Invalid before cursor")
+		}
+	case after != "":
+		if query.After, err = model.DecodeCursor(after); err != nil {
+			return nil, errors.New("Invalid after cursor")
+		}
+	case since != "":
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return nil, errors.New("Invalid since timestamp (expected RFC 3339)")
+		}
+		query.Since = &t
+	}
+
+	return query, nil
 }
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"social-media-app/internal/model"
 	"social-media-app/internal/service"
+	"social-media-app/internal/websocket"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,10 +14,14 @@ import (
 
 type PostHandler struct {
 	service *service.PostService
+	hub     *websocket.Hub
 }
 
-func NewPostHandler(service *service.PostService) *PostHandler {
-	return &PostHandler{service: service}
+func NewPostHandler(service *service.PostService, hub *websocket.Hub) *PostHandler {
+	return &PostHandler{
+		service: service,
+		hub:     hub,
+	}
 }
 
 func (h *PostHandler) CreatePost(c *gin.Context) {
@@ -31,7 +38,7 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
-	post, err := h.service.CreatePost(userID.(uuid.UUID), &req)
+	post, err := h.service.CreatePost(c.Request.Context(), userID.(uuid.UUID), &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -43,18 +50,97 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 	})
 }
 
-func (h *PostHandler) GetPosts(c *gin.Context) {
-	posts, err := h.service.GetAllPosts()
+func (h *PostHandler) UpdatePost(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	var req model.UpdatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	post, err := h.service.UpdatePost(c.Request.Context(), id, userID.(uuid.UUID), &req)
+	if err != nil {
+		respondPostError(c, err)
+		return
+	}
+
+	// Broadcast to WebSocket clients if hub is available
+	if h.hub != nil {
+		h.hub.BroadcastEvent(c.Request.Context(), "post_updated", id.String(), post)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"message": "Post updated successfully",
+		"post":    post,
 	})
 }
 
+func (h *PostHandler) DeletePost(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.service.DeletePost(c.Request.Context(), id, userID.(uuid.UUID)); err != nil {
+		respondPostError(c, err)
+		return
+	}
+
+	// Broadcast to WebSocket clients if hub is available
+	if h.hub != nil {
+		h.hub.BroadcastEvent(c.Request.Context(), "post_deleted", id.String(), gin.H{"id": id})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
+}
+
+// respondPostError maps post ownership errors to HTTP statuses
+func respondPostError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPostNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *PostHandler) GetPosts(c *gin.Context) {
+	cursor, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.service.GetPostsPage(c.Request.Context(), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
 func (h *PostHandler) GetPost(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -63,7 +149,7 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 		return
 	}
 
-	post, err := h.service.GetPost(id)
+	post, err := h.service.GetPost(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -78,3 +164,23 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 		"post": post,
 	})
 }
+
+// parsePageParams reads the cursor and limit query params, responding with 400 if they are invalid
+func parsePageParams(c *gin.Context) (*model.Cursor, int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return nil, 0, false
+	}
+
+	var cursor *model.Cursor
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err = model.DecodeCursor(cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return nil, 0, false
+		}
+	}
+
+	return cursor, limit, true
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"social-media-app/internal/model"
+	"social-media-app/internal/service"
+	"social-media-app/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReactionHandler struct {
+	service *service.ReactionService
+	hub     *websocket.Hub
+}
+
+func NewReactionHandler(service *service.ReactionService, hub *websocket.Hub) *ReactionHandler {
+	return &ReactionHandler{
+		service: service,
+		hub:     hub,
+	}
+}
+
+// AddPostReaction handles PUT /posts/:id/reactions/:kind
+func (h *ReactionHandler) AddPostReaction(c *gin.Context) {
+	h.setPostReaction(c, true)
+}
+
+// RemovePostReaction handles DELETE /posts/:id/reactions/:kind
+func (h *ReactionHandler) RemovePostReaction(c *gin.Context) {
+	h.setPostReaction(c, false)
+}
+
+// AddMessageReaction handles PUT /posts/:id/messages/:messageId/reactions/:kind
+func (h *ReactionHandler) AddMessageReaction(c *gin.Context) {
+	h.setMessageReaction(c, true)
+}
+
+// RemoveMessageReaction handles DELETE /posts/:id/messages/:messageId/reactions/:kind
+func (h *ReactionHandler) RemoveMessageReaction(c *gin.Context) {
+	h.setMessageReaction(c, false)
+}
+
+func (h *ReactionHandler) setPostReaction(c *gin.Context, on bool) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	update, err := h.service.SetPostReaction(c.Request.Context(), postID, userID.(uuid.UUID), c.Param("kind"), on)
+	h.respond(c, update, err)
+}
+
+func (h *ReactionHandler) setMessageReaction(c *gin.Context, on bool) {
+	postID, messageID, ok := parseMessagePath(c)
+	if !ok {
+		return
+	}
+
+	// Extract user ID from JWT token (set by middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	update, err := h.service.SetMessageReaction(c.Request.Context(), postID, messageID, userID.(uuid.UUID), c.Param("kind"), on)
+	h.respond(c, update, err)
+}
+
+func (h *ReactionHandler) respond(c *gin.Context, update *model.ReactionUpdate, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidReaction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrPostNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	case errors.Is(err, service.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only broadcast real changes; repeated requests are no-ops
+	if update.Changed && h.hub != nil {
+		h.hub.BroadcastEvent(c.Request.Context(), "reaction_updated", update.PostID.String(), update)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reaction": update})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"social-media-app/internal/service"
+	"social-media-app/internal/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagHandler struct {
+	postService   *service.PostService
+	entityService *service.EntityService
+}
+
+func NewTagHandler(postService *service.PostService, entityService *service.EntityService) *TagHandler {
+	return &TagHandler{
+		postService:   postService,
+		entityService: entityService,
+	}
+}
+
+func (h *TagHandler) GetTagPosts(c *gin.Context) {
+	tag := utils.NormalizeHashtag(c.Param("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hashtag"})
+		return
+	}
+
+	cursor, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.postService.GetTagPostsPage(c.Request.Context(), tag, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *TagHandler) GetTrendingHashtags(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	hashtags, err := h.entityService.GetTrendingHashtags(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hashtags": hashtags,
+		"window":   service.TrendingWindow.String(),
+	})
+}
@@ -60,6 +60,31 @@ var (
 		[]string{"operation"},
 	)
 
+	// Cache metrics
+	cacheRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_requests_total",
+			Help: "Total number of cache lookups by cache and result (hit, miss, coalesced)",
+		},
+		[]string{"cache", "result"},
+	)
+
+	cacheEarlyRefreshesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_early_refreshes_total",
+			Help: "Total number of cache entries recomputed before expiring",
+		},
+		[]string{"cache"},
+	)
+
+	cacheTierLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_tier_lookups_total",
+			Help: "Total number of lookups per cache tier (l1, redis) and result (hit, miss)",
+		},
+		[]string{"tier", "result"},
+	)
+
 	// WebSocket metrics
 	websocketConnectionsActive = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -76,6 +101,30 @@ var (
 		[]string{"type"},
 	)
 
+	websocketDisconnectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_disconnects_total",
+			Help: "Total number of WebSocket disconnections by close reason",
+		},
+		[]string{"reason"},
+	)
+
+	websocketDroppedMessagesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_dropped_messages_total",
+			Help: "Total number of WebSocket messages dropped for slow consumers by policy, or by the relay (policy \"relay\") when Redis falls behind",
+		},
+		[]string{"policy"},
+	)
+
+	websocketClientDroppedMessages = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "websocket_client_dropped_messages",
+			Help:    "Messages dropped per WebSocket client over its lifetime",
+			Buckets: []float64{0, 1, 10, 100, 1000, 10000},
+		},
+	)
+
 	// Business metrics
 	usersRegisteredTotal = promauto.NewCounter(
 		prometheus.CounterOpts{
@@ -139,6 +188,18 @@ func IncrementWebSocketMessages(messageType string) {
 	websocketMessagesTotal.WithLabelValues(messageType).Inc()
 }
 
+func IncrementWebSocketDisconnects(reason string) {
+	websocketDisconnectsTotal.WithLabelValues(reason).Inc()
+}
+
+func IncrementWebSocketDroppedMessages(policy string) {
+	websocketDroppedMessagesTotal.WithLabelValues(policy).Inc()
+}
+
+func ObserveWebSocketClientDroppedMessages(count int64) {
+	websocketClientDroppedMessages.Observe(float64(count))
+}
+
 func IncrementDBQueries(operation, table string) {
 	dbQueriesTotal.WithLabelValues(operation, table).Inc()
 }
@@ -147,6 +208,22 @@ func IncrementRedisOperations(operation string) {
 	redisOperationsTotal.WithLabelValues(operation).Inc()
 }
 
+func IncrementCacheRequests(cache, result string) {
+	cacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
+
+func IncrementCacheEarlyRefreshes(cache string) {
+	cacheEarlyRefreshesTotal.WithLabelValues(cache).Inc()
+}
+
+func ObserveCacheTierLookup(tier string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheTierLookupsTotal.WithLabelValues(tier, result).Inc()
+}
+
 func SetActiveDBConnections(count float64) {
 	dbConnectionsActive.Set(count)
 }
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"social-media-app/internal/service"
+	"social-media-app/internal/utils"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -10,13 +14,58 @@ import (
 )
 
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	SessionID string    `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+var (
+	ErrInvalidClaims      = errors.New("invalid token claims")
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrSessionUnavailable = errors.New("unable to verify session")
+)
+
+// ValidateToken parses an access token and checks that its session is still
+// active. Expired tokens yield an error matching jwt.ErrTokenExpired.
+func ValidateToken(ctx context.Context, keys *utils.KeyManager, sessions *service.SessionService, tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.Keyfunc, jwt.WithValidMethods(keys.ValidMethods()))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidClaims
+	}
+
+	// Reject tokens whose session was logged out or revoked
+	active, err := sessions.IsActive(ctx, claims.SessionID)
+	if err != nil {
+		return nil, ErrSessionUnavailable
+	}
+	if !active {
+		return nil, ErrSessionRevoked
+	}
+
+	return claims, nil
+}
+
+// TokenAuthenticator validates raw access tokens for transports that cannot
+// send an Authorization header, such as browser WebSocket handshakes
+func TokenAuthenticator(keys *utils.KeyManager, sessions *service.SessionService) func(context.Context, string) (uuid.UUID, error) {
+	return func(ctx context.Context, tokenString string) (uuid.UUID, error) {
+		claims, err := ValidateToken(ctx, keys, sessions, tokenString)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		return claims.UserID, nil
+	}
+}
+
+// AuthMiddleware requires a valid access token whose session has not been revoked
+func AuthMiddleware(keys *utils.KeyManager, sessions *service.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -34,32 +83,35 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		}
 
 		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		claims, err := ValidateToken(c.Request.Context(), keys, sessions, tokenString)
+		switch {
+		case errors.Is(err, ErrSessionUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify session"})
 			c.Abort()
 			return
-		}
-
-		// Extract claims
-		if claims, ok := token.Claims.(*Claims); ok {
-			c.Set("user_id", claims.UserID)
-			c.Set("username", claims.Username)
-			c.Set("email", claims.Email)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		case errors.Is(err, ErrSessionRevoked):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		setClaims(c, claims)
 		c.Next()
 	}
 }
 
-func OptionalAuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func setClaims(c *gin.Context, claims *Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("email", claims.Email)
+	c.Set("session_id", claims.SessionID)
+}
+
+func OptionalAuthMiddleware(keys *utils.KeyManager, sessions *service.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -73,16 +125,8 @@ func OptionalAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err == nil && token.Valid {
-			if claims, ok := token.Claims.(*Claims); ok {
-				c.Set("user_id", claims.UserID)
-				c.Set("username", claims.Username)
-				c.Set("email", claims.Email)
-			}
+		if claims, err := ValidateToken(c.Request.Context(), keys, sessions, tokenString); err == nil {
+			setClaims(c, claims)
 		}
 
 		c.Next()
@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
 )
@@ -49,54 +51,51 @@ func (rl *RateLimiter) UserRateLimit(requestsPerMinute int) gin.HandlerFunc {
 			identifier = fmt.Sprintf("ip:%s", c.ClientIP())
 		}
 
-		key := fmt.Sprintf("rate_limit:%s", identifier)
+		allowed, remaining, reset := rl.allow(c.Request.Context(), identifier, requestsPerMinute)
 
-		// Use Redis sliding window rate limiting
-		ctx := c.Request.Context()
-
-		// Get current count
-		current, err := rl.redisClient.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			// If Redis is down, allow the request
-			c.Next()
-			return
-		}
-
-		if current >= requestsPerMinute {
-			// Get TTL for retry-after header
-			ttl, _ := rl.redisClient.TTL(ctx, key).Result()
-
-			c.Header("X-RateLimit-Limit", strconv.Itoa(requestsPerMinute))
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(requestsPerMinute))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(reset).Unix(), 10))
 
+		if !allowed {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
-				"retry_after": ttl.String(),
+				"retry_after": reset.String(),
 			})
 			c.Abort()
 			return
 		}
 
-		// Increment counter
-		pipe := rl.redisClient.Pipeline()
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, time.Minute)
-		_, err = pipe.Exec(ctx)
+		c.Next()
+	}
+}
 
-		if err != nil {
-			// If Redis is down, allow the request
-			c.Next()
-			return
-		}
+// allow counts a request against the identifier's per-minute budget and
+// returns whether it is allowed, how many requests remain and when the window
+// resets. Requests are allowed if Redis is unavailable.
+func (rl *RateLimiter) allow(ctx context.Context, identifier string, requestsPerMinute int) (bool, int, time.Duration) {
+	key := fmt.Sprintf("rate_limit:%s", identifier)
 
-		// Set rate limit headers
-		c.Header("X-RateLimit-Limit", strconv.Itoa(requestsPerMinute))
-		c.Header("X-RateLimit-Remaining", strconv.Itoa(requestsPerMinute-current-1))
-		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	// Get current count
+	current, err := rl.redisClient.Get(ctx, key).Int()
+	if err != nil && err != redis.Nil {
+		return true, requestsPerMinute, time.Minute
+	}
 
-		c.Next()
+	if current >= requestsPerMinute {
+		ttl, _ := rl.redisClient.TTL(ctx, key).Result()
+		return false, 0, ttl
+	}
+
+	// Increment counter
+	pipe := rl.redisClient.Pipeline()
+	pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return true, requestsPerMinute, time.Minute
 	}
+
+	return true, requestsPerMinute - current - 1, time.Minute
 }
 
 // API endpoint specific rate limits
@@ -108,6 +107,16 @@ func (rl *RateLimiter) PostCreationRateLimit() gin.HandlerFunc {
 	return rl.UserRateLimit(10) // 10 posts per minute
 }
 
+// messagesPerMinute is shared by the HTTP and WebSocket message endpoints
+const messagesPerMinute = 60
+
 func (rl *RateLimiter) MessageRateLimit() gin.HandlerFunc {
-	return rl.UserRateLimit(60) // 60 messages per minute
+	return rl.UserRateLimit(messagesPerMinute)
+}
+
+// AllowMessage applies MessageRateLimit's budget to messages sent outside HTTP,
+// such as over a WebSocket. It returns how long to wait when denied.
+func (rl *RateLimiter) AllowMessage(userID uuid.UUID) (bool, time.Duration) {
+	allowed, _, reset := rl.allow(context.Background(), fmt.Sprintf("user:%s", userID), messagesPerMinute)
+	return allowed, reset
 }
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxConversationParticipants bounds group conversations, creator included
+const MaxConversationParticipants = 10
+
+// Conversation is a private 1:1 or small-group thread. DirectKey identifies
+// 1:1 conversations ("<userID>:<userID>", sorted) so each pair has only one;
+// it is nil for groups.
+type Conversation struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	DirectKey     *string   `json:"-" gorm:"uniqueIndex"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastMessageAt time.Time `json:"last_message_at" gorm:"not null;index"`
+
+	// Relations
+	Participants []ConversationParticipant `json:"participants" gorm:"foreignKey:ConversationID"`
+
+	// Per-viewer fields
+	LastMessage *DirectMessage `json:"last_message,omitempty" gorm:"-"`
+	UnreadCount int64          `json:"unread_count" gorm:"-"`
+}
+
+// BeforeCreate hook to generate UUID
+func (c *Conversation) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// Cursor returns the position of this conversation in a list ordered by latest activity
+func (c *Conversation) Cursor() *Cursor {
+	return &Cursor{CreatedAt: c.LastMessageAt, ID: c.ID}
+}
+
+// HasParticipant reports whether the user takes part in the conversation
+func (c *Conversation) HasParticipant(userID uuid.UUID) bool {
+	for _, participant := range c.Participants {
+		if participant.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ParticipantIDs returns the IDs of everyone in the conversation
+func (c *Conversation) ParticipantIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(c.Participants))
+	for _, participant := range c.Participants {
+		ids = append(ids, participant.UserID)
+	}
+	return ids
+}
+
+// ConversationParticipant is a member of a conversation and their read
+// position: the last message they read and its creation time, which unread
+// counts compare against
+type ConversationParticipant struct {
+	ConversationID    uuid.UUID  `json:"-" gorm:"type:uuid;primaryKey"`
+	UserID            uuid.UUID  `json:"user_id" gorm:"type:uuid;primaryKey;index"`
+	LastReadMessageID *uuid.UUID `json:"last_read_message_id,omitempty" gorm:"type:uuid"`
+	LastReadMessageAt *time.Time `json:"-"`
+	JoinedAt          time.Time  `json:"joined_at" gorm:"autoCreateTime"`
+
+	// Relations
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// DirectMessage is a message in a conversation
+type DirectMessage struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ConversationID uuid.UUID `json:"conversation_id" gorm:"type:uuid;not null;index:idx_direct_messages_conversation_created,priority:1"`
+	SenderID       uuid.UUID `json:"sender_id" gorm:"type:uuid;not null"`
+	Message        string    `json:"message" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index:idx_direct_messages_conversation_created,priority:2"`
+
+	// Relations
+	Sender User `json:"sender" gorm:"foreignKey:SenderID"`
+}
+
+// BeforeCreate hook to generate UUID
+func (m *DirectMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// Cursor returns the position of this message in its conversation
+func (m *DirectMessage) Cursor() *Cursor {
+	return &Cursor{CreatedAt: m.CreatedAt, ID: m.ID}
+}
+
+// ConversationPage is a page of conversations, most recently active first
+type ConversationPage struct {
+	Conversations []*Conversation `json:"conversations"`
+	NextCursor    string          `json:"next_cursor,omitempty"`
+}
+
+// DirectMessagePage is a page of a conversation's messages, newest first
+type DirectMessagePage struct {
+	Messages   []*DirectMessage `json:"messages"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// ReadReceipt records how far a participant has read a conversation
+type ReadReceipt struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	MessageID      uuid.UUID `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// CreateConversationRequest lists the other participants; one makes a 1:1
+// conversation. max is MaxConversationParticipants minus the creator.
+type CreateConversationRequest struct {
+	ParticipantIDs []uuid.UUID `json:"participant_ids" binding:"required,min=1,max=9"`
+}
+
+type SendDirectMessageRequest struct {
+	Message string `json:"message" binding:"required,max=2000"`
+}
+
+// MarkReadRequest marks a conversation read up to MessageID, or up to its
+// latest message when omitted
+type MarkReadRequest struct {
+	MessageID *uuid.UUID `json:"message_id"`
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Entities are the mentions and hashtags found in a post caption or message.
+// Start and End are offsets into the text in Unicode code points, End exclusive.
+// Stored as JSON alongside the text so every read path, cached or not, returns them.
+type Entities struct {
+	Mentions []Mention `json:"mentions,omitempty"`
+	Hashtags []Hashtag `json:"hashtags,omitempty"`
+}
+
+// Mention is an @username that resolved to an existing user
+type Mention struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Start    int       `json:"start"`
+	End      int       `json:"end"`
+}
+
+// Hashtag is a #tag; Tag is lowercased and without the '#'
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Value stores entities as JSON
+func (e Entities) Value() (driver.Value, error) {
+	return json.Marshal(e)
+}
+
+// Scan reads entities stored as JSON
+func (e *Entities) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	default:
+		return errors.New("unsupported entities value")
+	}
+}
+
+// MentionedUserIDs returns each mentioned user once
+func (e *Entities) MentionedUserIDs() []uuid.UUID {
+	if e == nil {
+		return nil
+	}
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	for _, mention := range e.Mentions {
+		if !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			ids = append(ids, mention.UserID)
+		}
+	}
+	return ids
+}
+
+// Tags returns each hashtag once
+func (e *Entities) Tags() []string {
+	if e == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var tags []string
+	for _, hashtag := range e.Hashtags {
+		if !seen[hashtag.Tag] {
+			seen[hashtag.Tag] = true
+			tags = append(tags, hashtag.Tag)
+		}
+	}
+	return tags
+}
+
+// PostMention links a post to a user mentioned in its caption
+type PostMention struct {
+	PostID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+}
+
+// PostHashtag links a post to a hashtag in its caption
+type PostHashtag struct {
+	PostID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Tag    string    `gorm:"primaryKey;index"`
+}
+
+// MessageMention links a message to a user mentioned in it
+type MessageMention struct {
+	MessageID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+}
+
+// MessageHashtag links a message to a hashtag in it
+type MessageHashtag struct {
+	MessageID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Tag       string    `gorm:"primaryKey;index"`
+}
+
+// TrendingHashtag is a hashtag and how often it was used in the trending window
+type TrendingHashtag struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
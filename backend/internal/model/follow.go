@@ -0,0 +1,13 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id" gorm:"type:uuid;primaryKey"`
+	FolloweeID uuid.UUID `json:"followee_id" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
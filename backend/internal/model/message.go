@@ -8,11 +8,18 @@ import (
 )
 
 type Message struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	PostID    uuid.UUID `json:"post_id" gorm:"type:uuid;not null"`
-	SenderID  uuid.UUID `json:"sender_id" gorm:"type:uuid;not null"`
-	Message   string    `json:"message" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PostID    uuid.UUID        `json:"post_id" gorm:"type:uuid;not null"`
+	SenderID  uuid.UUID        `json:"sender_id" gorm:"type:uuid;not null"`
+	Message   string           `json:"message" gorm:"not null"`
+	Entities  *Entities        `json:"entities,omitempty" gorm:"type:jsonb"`
+	CreatedAt time.Time        `json:"created_at"`
+	EditedAt  *time.Time       `json:"edited_at,omitempty"`
+	DeletedAt gorm.DeletedAt   `json:"-" gorm:"index"`
+	Reactions map[string]int64 `json:"reactions,omitempty" gorm:"-"`
+
+	// Full-text search document, maintained by the repository and never loaded
+	SearchVector string `json:"-" gorm:"type:tsvector;->:false;<-:false;index:idx_messages_search,type:gin"`
 
 	// Relations
 	Post   Post `json:"post,omitempty" gorm:"foreignKey:PostID"`
@@ -27,6 +34,31 @@ func (m *Message) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// Cursor returns the position of this message in its post's history
+func (m *Message) Cursor() *Cursor {
+	return &Cursor{CreatedAt: m.CreatedAt, ID: m.ID}
+}
+
+// MessageQuery selects a window of a post's message history. At most one of
+// Before, After and Since is set; with none set the latest messages are returned.
+type MessageQuery struct {
+	Before *Cursor
+	After  *Cursor
+	Since  *time.Time
+	Limit  int
+}
+
+// MessagePage is a window of a post's messages, oldest first
+type MessagePage struct {
+	Messages   []*Message `json:"messages"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
 type CreateMessageRequest struct {
 	Message string `json:"message" binding:"required,max=500"`
 }
+
+type UpdateMessageRequest struct {
+	Message string `json:"message" binding:"required,max=500"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotificationComment = "comment"
+	NotificationFollow  = "follow"
+	NotificationMention = "mention"
+)
+
+// Notification tells a user that others interacted with them. Repeated events
+// of the same kind on the same target collapse into one unread notification
+// ("5 people commented on your post"): GroupKey identifies the kind and target,
+// Actor is the latest actor and ActorCount the number of distinct actors.
+// Once read, the next event starts a new notification.
+type Notification struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID  `json:"-" gorm:"type:uuid;not null;uniqueIndex:idx_notifications_unread_group,where:read_at IS NULL;index:idx_notifications_user_updated,priority:1"`
+	GroupKey   string     `json:"-" gorm:"not null;uniqueIndex:idx_notifications_unread_group,where:read_at IS NULL"`
+	Type       string     `json:"type" gorm:"not null"`
+	PostID     *uuid.UUID `json:"post_id,omitempty" gorm:"type:uuid"`
+	ActorID    uuid.UUID  `json:"actor_id" gorm:"type:uuid;not null"`
+	ActorCount int        `json:"actor_count" gorm:"not null;default:1"`
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"index:idx_notifications_user_updated,priority:2"`
+
+	// Relations
+	Actor User `json:"actor" gorm:"foreignKey:ActorID"`
+}
+
+// BeforeCreate hook to generate UUID
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
+
+// Cursor returns the position of this notification in a list ordered by latest activity
+func (n *Notification) Cursor() *Cursor {
+	return &Cursor{CreatedAt: n.UpdatedAt, ID: n.ID}
+}
+
+// NotificationActor records who contributed to a collapsed notification, so
+// repeated events by the same user are not counted twice
+type NotificationActor struct {
+	NotificationID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ActorID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+}
+
+// NotificationPage is a page of notifications, most recently active first
+type NotificationPage struct {
+	Notifications []*Notification `json:"notifications"`
+	NextCursor    string          `json:"next_cursor,omitempty"`
+}
+
+// NotificationEvent is pushed to the recipient's sockets when a notification
+// is created or updated, with their new unread count for badges
+type NotificationEvent struct {
+	Notification *Notification `json:"notification"`
+	UnreadCount  int64         `json:"unread_count"`
+}
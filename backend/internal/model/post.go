@@ -8,12 +8,18 @@ import (
 )
 
 type Post struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
-	ImageURL  string    `json:"image_url"`
-	Caption   string    `json:"caption"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID        `json:"user_id" gorm:"type:uuid;not null"`
+	ImageURL  string           `json:"image_url"`
+	Caption   string           `json:"caption"`
+	Entities  *Entities        `json:"entities,omitempty" gorm:"type:jsonb"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	DeletedAt gorm.DeletedAt   `json:"-" gorm:"index"`
+	Reactions map[string]int64 `json:"reactions,omitempty" gorm:"-"`
+
+	// Full-text search document, maintained by the repository and never loaded
+	SearchVector string `json:"-" gorm:"type:tsvector;->:false;<-:false;index:idx_posts_search,type:gin"`
 
 	// Relations
 	User     User      `json:"user" gorm:"foreignKey:UserID"`
@@ -32,6 +38,22 @@ type CreatePostRequest struct {
 	ImageURL string `json:"image_url" binding:"required"`
 	Caption  string `json:"caption"`
 }
+
+// PostPage is one page of the post feed
+type PostPage struct {
+	Posts      []*Post `json:"posts"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Cursor returns the position just after this post in the feed
+func (p *Post) Cursor() *Cursor {
+	return &Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+type UpdatePostRequest struct {
+	Caption string `json:"caption"`
+}
+
 type UploadResponse struct {
 	URL      string `json:"url"`
 	Filename string `json:"filename"`
@@ -0,0 +1,16 @@
+package model
+
+import "github.com/google/uuid"
+
+// PresenceUser is a user currently viewing a post
+type PresenceUser struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+// PresenceSnapshot lists the users currently viewing a post, across all instances
+type PresenceSnapshot struct {
+	PostID uuid.UUID      `json:"post_id"`
+	Users  []PresenceUser `json:"users"`
+	Count  int            `json:"count"`
+}
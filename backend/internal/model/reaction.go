@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReactionTargetPost    = "post"
+	ReactionTargetMessage = "message"
+)
+
+// ReactionKinds is the fixed set of reactions users can leave
+var ReactionKinds = map[string]bool{
+	"like":  true,
+	"love":  true,
+	"laugh": true,
+	"wow":   true,
+	"sad":   true,
+	"angry": true,
+}
+
+type Reaction struct {
+	TargetType string    `json:"target_type" gorm:"primaryKey;size:16"`
+	TargetID   uuid.UUID `json:"target_id" gorm:"type:uuid;primaryKey"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	Kind       string    `json:"kind" gorm:"primaryKey;size:16"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReactionUpdate describes the new count of one reaction kind on a post or message
+type ReactionUpdate struct {
+	TargetType string    `json:"target_type"`
+	TargetID   uuid.UUID `json:"target_id"`
+	PostID     uuid.UUID `json:"post_id"`
+	Kind       string    `json:"kind"`
+	Count      int64     `json:"count"`
+	Changed    bool      `json:"-"`
+}
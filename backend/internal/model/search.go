@@ -0,0 +1,90 @@
+package model
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Search result types
+const (
+	SearchPosts    = "posts"
+	SearchMessages = "messages"
+	SearchUsers    = "users"
+)
+
+// SearchCursor identifies a position in search results ordered by (rank, id)
+type SearchCursor struct {
+	Rank float64
+	ID   uuid.UUID
+}
+
+// Encode returns an opaque, URL-safe representation of the cursor
+func (c *SearchCursor) Encode() string {
+	raw := strconv.FormatFloat(c.Rank, 'g', -1, 64) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeSearchCursor parses a cursor produced by SearchCursor.Encode
+func DecodeSearchCursor(s string) (*SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	rank, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &SearchCursor{Rank: rank, ID: id}, nil
+}
+
+// SearchHit is a matching row as ranked by the database
+type SearchHit struct {
+	ID      uuid.UUID
+	Rank    float64
+	Snippet string
+}
+
+// Cursor returns the position just after this hit
+func (h *SearchHit) Cursor() *SearchCursor {
+	return &SearchCursor{Rank: h.Rank, ID: h.ID}
+}
+
+// SearchUser is the public part of a user shown in search results
+type SearchUser struct {
+	ID            uuid.UUID `json:"id"`
+	Username      string    `json:"username"`
+	FollowerCount int       `json:"follower_count"`
+}
+
+// SearchResult is one match. Exactly one of Post, Message and User is set,
+// depending on the search type. Snippet is the HTML-escaped matching text
+// with matches wrapped in <mark>…</mark>, safe to render as HTML.
+type SearchResult struct {
+	Rank    float64     `json:"rank"`
+	Snippet string      `json:"snippet,omitempty"`
+	Post    *Post       `json:"post,omitempty"`
+	Message *Message    `json:"message,omitempty"`
+	User    *SearchUser `json:"user,omitempty"`
+}
+
+// SearchPage is a page of search results, best match first
+type SearchPage struct {
+	Type       string          `json:"type"`
+	Results    []*SearchResult `json:"results"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a server-side login session. Its refresh token rotates on every
+// use; only hashes are stored, of the current token and of the latest
+// rotated-out ones, so a replayed token can be told apart from a wrong one.
+type Session struct {
+	ID             string    `json:"id"`
+	UserID         uuid.UUID `json:"user_id"`
+	RefreshHash    string    `json:"refresh_hash"`
+	PreviousHashes []string  `json:"previous_hashes,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
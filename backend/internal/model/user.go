@@ -8,12 +8,14 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Username     string    `json:"username" gorm:"uniqueIndex;not null"`
-	Email        string    `json:"email" gorm:"uniqueIndex;not null"`
-	PasswordHash string    `json:"-" gorm:"not null"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Username       string    `json:"username" gorm:"uniqueIndex;not null;index:idx_users_username_trgm,type:gin,expression:username gin_trgm_ops"`
+	Email          string    `json:"email" gorm:"uniqueIndex;not null"`
+	PasswordHash   string    `json:"-" gorm:"not null"`
+	FollowerCount  int       `json:"follower_count" gorm:"not null;default:0"`
+	FollowingCount int       `json:"following_count" gorm:"not null;default:0"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -37,5 +39,6 @@ type LoginRequest struct {
 
 type LoginResponse struct {
 	Token string `json:"token"`
-	User  User   `json:"user"`
+	TokenPair
+	User User `json:"user"`
 }
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ConversationRepository struct {
+	db *gorm.DB
+}
+
+func NewConversationRepository(db *gorm.DB) *ConversationRepository {
+	return &ConversationRepository{db: db}
+}
+
+// Create stores a conversation together with its participants
+func (r *ConversationRepository) Create(conversation *model.Conversation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(conversation).Error; err != nil {
+			return err
+		}
+		for i := range conversation.Participants {
+			conversation.Participants[i].ConversationID = conversation.ID
+		}
+		return tx.Omit("User").Create(&conversation.Participants).Error
+	})
+}
+
+// GetByID returns a conversation with its participants
+func (r *ConversationRepository) GetByID(id uuid.UUID) (*model.Conversation, error) {
+	var conversation model.Conversation
+	err := r.db.Preload("Participants.User").First(&conversation, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetByDirectKey returns the 1:1 conversation of a pair of users
+func (r *ConversationRepository) GetByDirectKey(key string) (*model.Conversation, error) {
+	var conversation model.Conversation
+	err := r.db.Preload("Participants.User").First(&conversation, "direct_key = ?", key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// GetPageForUser returns up to limit conversations of a user, most recently
+// active first, strictly after the cursor
+func (r *ConversationRepository) GetPageForUser(userID uuid.UUID, cursor *model.Cursor, limit int) ([]*model.Conversation, error) {
+	var conversations []*model.Conversation
+	query := r.db.Preload("Participants.User").
+		Joins("JOIN conversation_participants p ON p.conversation_id = conversations.id AND p.user_id = ?", userID).
+		Order("conversations.last_message_at desc, conversations.id desc").
+		Limit(limit)
+	if cursor != nil {
+		query = query.Where("(conversations.last_message_at, conversations.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	err := query.Find(&conversations).Error
+	return conversations, err
+}
+
+// CreateMessage stores a message and bumps the conversation's activity time
+func (r *ConversationRepository) CreateMessage(message *model.DirectMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Conversation{}).Where("id = ?", message.ConversationID).
+			UpdateColumn("last_message_at", message.CreatedAt).Error
+	})
+}
+
+func (r *ConversationRepository) GetMessageByID(id uuid.UUID) (*model.DirectMessage, error) {
+	var message model.DirectMessage
+	err := r.db.First(&message, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetMessages returns up to limit messages of a conversation older than the
+// cursor (nil for the latest), newest first
+func (r *ConversationRepository) GetMessages(conversationID uuid.UUID, cursor *model.Cursor, limit int) ([]*model.DirectMessage, error) {
+	var messages []*model.DirectMessage
+	query := r.db.Preload("Sender").Where("conversation_id = ?", conversationID).
+		Order("created_at desc, id desc").Limit(limit)
+	if cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	err := query.Find(&messages).Error
+	return messages, err
+}
+
+// GetLatestMessages returns the newest message of each given conversation, keyed by conversation
+func (r *ConversationRepository) GetLatestMessages(conversationIDs []uuid.UUID) (map[uuid.UUID]*model.DirectMessage, error) {
+	latest := make(map[uuid.UUID]*model.DirectMessage, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return latest, nil
+	}
+
+	var messages []*model.DirectMessage
+	err := r.db.Preload("Sender").
+		Where("id IN (?)", r.db.Model(&model.DirectMessage{}).
+			Select("DISTINCT ON (conversation_id) id").
+			Where("conversation_id IN ?", conversationIDs).
+			Order("conversation_id, created_at desc, id desc")).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range messages {
+		latest[message.ConversationID] = message
+	}
+	return latest, nil
+}
+
+// MarkRead moves a participant's read position forward to the given message.
+// It reports false if they had already read that far.
+func (r *ConversationRepository) MarkRead(conversationID, userID uuid.UUID, message *model.DirectMessage) (bool, error) {
+	result := r.db.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Where("(last_read_message_at IS NULL OR last_read_message_at < ?)", message.CreatedAt).
+		Updates(map[string]interface{}{
+			"last_read_message_id": message.ID,
+			"last_read_message_at": message.CreatedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// unreadQuery selects, per conversation of a user, the messages from others
+// newer than the user's read position
+func (r *ConversationRepository) unreadQuery(userID uuid.UUID) *gorm.DB {
+	return r.db.Table("conversation_participants p").
+		Joins("JOIN direct_messages m ON m.conversation_id = p.conversation_id AND m.sender_id <> p.user_id").
+		Where("p.user_id = ?", userID).
+		Where("(p.last_read_message_at IS NULL OR m.created_at > p.last_read_message_at)")
+}
+
+// GetUnreadCounts returns the number of unread messages per conversation for a user
+func (r *ConversationRepository) GetUnreadCounts(userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ConversationID uuid.UUID
+		Count          int64
+	}
+	err := r.unreadQuery(userID).
+		Where("p.conversation_id IN ?", conversationIDs).
+		Select("p.conversation_id, COUNT(*) AS count").
+		Group("p.conversation_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ConversationID] = row.Count
+	}
+	return counts, nil
+}
+
+// CountUnread returns a user's unread messages across all conversations
+func (r *ConversationRepository) CountUnread(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.unreadQuery(userID).Count(&count).Error
+	return count, err
+}
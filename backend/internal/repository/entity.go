@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// createPostEntities links a post to the users it mentions and its hashtags
+func createPostEntities(tx *gorm.DB, postID uuid.UUID, entities *model.Entities) error {
+	var mentions []model.PostMention
+	for _, userID := range entities.MentionedUserIDs() {
+		mentions = append(mentions, model.PostMention{PostID: postID, UserID: userID})
+	}
+	if len(mentions) > 0 {
+		if err := tx.Create(&mentions).Error; err != nil {
+			return err
+		}
+	}
+
+	var hashtags []model.PostHashtag
+	for _, tag := range entities.Tags() {
+		hashtags = append(hashtags, model.PostHashtag{PostID: postID, Tag: tag})
+	}
+	if len(hashtags) > 0 {
+		return tx.Create(&hashtags).Error
+	}
+	return nil
+}
+
+// createMessageEntities links a message to the users it mentions and its hashtags
+func createMessageEntities(tx *gorm.DB, messageID uuid.UUID, entities *model.Entities) error {
+	var mentions []model.MessageMention
+	for _, userID := range entities.MentionedUserIDs() {
+		mentions = append(mentions, model.MessageMention{MessageID: messageID, UserID: userID})
+	}
+	if len(mentions) > 0 {
+		if err := tx.Create(&mentions).Error; err != nil {
+			return err
+		}
+	}
+
+	var hashtags []model.MessageHashtag
+	for _, tag := range entities.Tags() {
+		hashtags = append(hashtags, model.MessageHashtag{MessageID: messageID, Tag: tag})
+	}
+	if len(hashtags) > 0 {
+		return tx.Create(&hashtags).Error
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FollowRepository struct {
+	db *gorm.DB
+}
+
+func NewFollowRepository(db *gorm.DB) *FollowRepository {
+	return &FollowRepository{db: db}
+}
+
+// Create records a follow and updates both users' counters. It reports false
+// if the follow already existed.
+func (r *FollowRepository) Create(followerID, followeeID uuid.UUID) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Follow{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+
+		return updateFollowCounts(tx, followerID, followeeID, 1)
+	})
+	return created, err
+}
+
+// Delete removes a follow and updates both users' counters. It reports false
+// if there was nothing to remove.
+func (r *FollowRepository) Delete(followerID, followeeID uuid.UUID) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&model.Follow{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = true
+
+		return updateFollowCounts(tx, followerID, followeeID, -1)
+	})
+	return deleted, err
+}
+
+func updateFollowCounts(tx *gorm.DB, followerID, followeeID uuid.UUID, delta int) error {
+	err := tx.Model(&model.User{}).Where("id = ?", followerID).
+		UpdateColumn("following_count", gorm.Expr("following_count + ?", delta)).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&model.User{}).Where("id = ?", followeeID).
+		UpdateColumn("follower_count", gorm.Expr("follower_count + ?", delta)).Error
+}
+
+func (r *FollowRepository) GetFollowerIDs(followeeID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&model.Follow{}).Where("followee_id = ?", followeeID).Pluck("follower_id", &ids).Error
+	return ids, err
+}
+
+// GetRegularFolloweeIDs returns the followed users with fewer than threshold followers
+func (r *FollowRepository) GetRegularFolloweeIDs(followerID uuid.UUID, threshold int) ([]uuid.UUID, error) {
+	return r.getFolloweeIDs(followerID, "users.follower_count < ?", threshold)
+}
+
+// GetPopularFolloweeIDs returns the followed users with at least threshold followers
+func (r *FollowRepository) GetPopularFolloweeIDs(followerID uuid.UUID, threshold int) ([]uuid.UUID, error) {
+	return r.getFolloweeIDs(followerID, "users.follower_count >= ?", threshold)
+}
+
+func (r *FollowRepository) getFolloweeIDs(followerID uuid.UUID, condition string, threshold int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&model.Follow{}).
+		Joins("JOIN users ON users.id = follows.followee_id").
+		Where("follows.follower_id = ?", followerID).
+		Where(condition, threshold).
+		Pluck("follows.followee_id", &ids).Error
+	return ids, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"social-media-app/internal/model"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -15,13 +16,91 @@ func NewMessageRepository(db *gorm.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
+// Create stores a message together with its mention and hashtag links and search document
 func (r *MessageRepository) Create(message *model.Message) error {
-	return r.db.Create(message).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		if err := updateMessageSearch(tx, message.ID); err != nil {
+			return err
+		}
+		return createMessageEntities(tx, message.ID, message.Entities)
+	})
+}
+
+// UpdateText replaces a message's text, entities and search document and marks it as edited
+func (r *MessageRepository) UpdateText(message *model.Message, text string, entities *model.Entities) error {
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(message).Updates(map[string]interface{}{
+			"message":   text,
+			"entities":  entities,
+			"edited_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := updateMessageSearch(tx, message.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&model.MessageMention{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&model.MessageHashtag{}).Error; err != nil {
+			return err
+		}
+		return createMessageEntities(tx, message.ID, entities)
+	})
+	if err != nil {
+		return err
+	}
+
+	message.Message = text
+	message.Entities = entities
+	message.EditedAt = &now
+	return nil
+}
+
+// Delete soft-deletes a message
+func (r *MessageRepository) Delete(message *model.Message) error {
+	return r.db.Delete(message).Error
+}
+
+// GetBefore returns the newest limit messages of a post older than the cursor
+// (nil for the latest messages), oldest first
+func (r *MessageRepository) GetBefore(postID uuid.UUID, cursor *model.Cursor, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	query := r.db.Preload("Sender").Where("post_id = ?", postID).Order("created_at desc, id desc").Limit(limit)
+	if cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	// Reverse into chronological order
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
 }
 
-func (r *MessageRepository) GetByPostID(postID uuid.UUID) ([]*model.Message, error) {
+// GetAfter returns the oldest limit messages of a post newer than the cursor, oldest first
+func (r *MessageRepository) GetAfter(postID uuid.UUID, cursor *model.Cursor, limit int) ([]*model.Message, error) {
 	var messages []*model.Message
-	err := r.db.Preload("Sender").Where("post_id = ?", postID).Order("created_at asc").Find(&messages).Error
+	err := r.db.Preload("Sender").
+		Where("post_id = ? AND (created_at, id) > (?, ?)", postID, cursor.CreatedAt, cursor.ID).
+		Order("created_at asc, id asc").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// GetSince returns the oldest limit messages of a post created after the given time, oldest first
+func (r *MessageRepository) GetSince(postID uuid.UUID, since time.Time, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := r.db.Preload("Sender").
+		Where("post_id = ? AND created_at > ?", postID, since).
+		Order("created_at asc, id asc").Limit(limit).Find(&messages).Error
 	return messages, err
 }
 
@@ -36,3 +115,12 @@ func (r *MessageRepository) GetByID(id uuid.UUID) (*model.Message, error) {
 	}
 	return &message, nil
 }
+
+func (r *MessageRepository) GetByIDs(ids []uuid.UUID) ([]*model.Message, error) {
+	var messages []*model.Message
+	if len(ids) == 0 {
+		return messages, nil
+	}
+	err := r.db.Preload("Sender").Where("id IN ?", ids).Find(&messages).Error
+	return messages, err
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"time"
+
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Record adds an event to the recipient's unread notification with the same
+// group key, creating it if there is none, and returns the notification. The
+// actor count only grows for actors not already part of the notification.
+func (r *NotificationRepository) Record(notification *model.Notification) (*model.Notification, error) {
+	var id uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Raw(`
+			INSERT INTO notifications (id, user_id, group_key, type, post_id, actor_id, actor_count, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)
+			ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+			DO UPDATE SET actor_id = EXCLUDED.actor_id, updated_at = EXCLUDED.updated_at
+			RETURNING id`,
+			uuid.New(), notification.UserID, notification.GroupKey, notification.Type,
+			notification.PostID, notification.ActorID, now, now,
+		).Scan(&id).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Exec(`INSERT INTO notification_actors (notification_id, actor_id) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+			id, notification.ActorID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&model.Notification{}).Where("id = ?", id).
+			UpdateColumn("actor_count", gorm.Expr("actor_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(id)
+}
+
+func (r *NotificationRepository) GetByID(id uuid.UUID) (*model.Notification, error) {
+	var notification model.Notification
+	err := r.db.Preload("Actor").First(&notification, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// GetPageForUser returns up to limit notifications of a user, most recently
+// active first, strictly after the cursor
+func (r *NotificationRepository) GetPageForUser(userID uuid.UUID, cursor *model.Cursor, limit int) ([]*model.Notification, error) {
+	var notifications []*model.Notification
+	query := r.db.Preload("Actor").Where("user_id = ?", userID).
+		Order("updated_at desc, id desc").Limit(limit)
+	if cursor != nil {
+		query = query.Where("(updated_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	err := query.Find(&notifications).Error
+	return notifications, err
+}
+
+// CountUnread returns the number of unread notifications of a user
+func (r *NotificationRepository) CountUnread(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks one of a user's notifications read. It reports false if the
+// notification does not exist, belongs to someone else or was already read.
+func (r *NotificationRepository) MarkRead(id, userID uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+		UpdateColumn("read_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkAllRead marks every unread notification of a user read and returns how many there were
+func (r *NotificationRepository) MarkAllRead(userID uuid.UUID) (int64, error) {
+	result := r.db.Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		UpdateColumn("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -15,13 +15,65 @@ func NewPostRepository(db *gorm.DB) *PostRepository {
 	return &PostRepository{db: db}
 }
 
+// Create stores a post together with its mention and hashtag links and search document
 func (r *PostRepository) Create(post *model.Post) error {
-	return r.db.Create(post).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		if err := updatePostSearch(tx, post.ID); err != nil {
+			return err
+		}
+		return createPostEntities(tx, post.ID, post.Entities)
+	})
+}
+
+// UpdateCaption replaces a post's caption along with the entities parsed from it
+// and its search document
+func (r *PostRepository) UpdateCaption(post *model.Post, caption string, entities *model.Entities) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(post).Updates(map[string]interface{}{
+			"caption":  caption,
+			"entities": entities,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := updatePostSearch(tx, post.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", post.ID).Delete(&model.PostMention{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", post.ID).Delete(&model.PostHashtag{}).Error; err != nil {
+			return err
+		}
+		return createPostEntities(tx, post.ID, entities)
+	})
+	if err != nil {
+		return err
+	}
+
+	post.Caption = caption
+	post.Entities = entities
+	return nil
+}
+
+// Delete soft-deletes a post
+func (r *PostRepository) Delete(post *model.Post) error {
+	return r.db.Delete(post).Error
+}
+
+// CountByImageURL returns how many live posts reference an image
+func (r *PostRepository) CountByImageURL(imageURL string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Post{}).Where("image_url = ?", imageURL).Count(&count).Error
+	return count, err
 }
 
 func (r *PostRepository) GetByID(id uuid.UUID) (*model.Post, error) {
 	var post model.Post
-	err := r.db.Preload("User").Preload("Messages.Sender").First(&post, "id = ?", id).Error
+	err := r.db.Preload("User").First(&post, "id = ?", id).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -31,9 +83,52 @@ func (r *PostRepository) GetByID(id uuid.UUID) (*model.Post, error) {
 	return &post, nil
 }
 
-func (r *PostRepository) GetAll() ([]*model.Post, error) {
+// GetPage returns up to limit posts, newest first, strictly after the given cursor (nil for the first page)
+func (r *PostRepository) GetPage(cursor *model.Cursor, limit int) ([]*model.Post, error) {
+	var posts []*model.Post
+	query := r.db.Preload("User").Order("created_at desc, id desc").Limit(limit)
+	if cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	err := query.Find(&posts).Error
+	return posts, err
+}
+
+// GetByIDs returns the posts with the given IDs, in no particular order
+func (r *PostRepository) GetByIDs(ids []uuid.UUID) ([]*model.Post, error) {
+	var posts []*model.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	err := r.db.Preload("User").Where("id IN ?", ids).Find(&posts).Error
+	return posts, err
+}
+
+// GetPageByUserIDs returns up to limit posts by any of the given users, newest first, strictly after the cursor
+func (r *PostRepository) GetPageByUserIDs(userIDs []uuid.UUID, cursor *model.Cursor, limit int) ([]*model.Post, error) {
+	var posts []*model.Post
+	if len(userIDs) == 0 {
+		return posts, nil
+	}
+	query := r.db.Preload("User").Where("user_id IN ?", userIDs).Order("created_at desc, id desc").Limit(limit)
+	if cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	err := query.Find(&posts).Error
+	return posts, err
+}
+
+// GetPageByTag returns up to limit posts tagged with a hashtag, newest first, strictly after the cursor
+func (r *PostRepository) GetPageByTag(tag string, cursor *model.Cursor, limit int) ([]*model.Post, error) {
 	var posts []*model.Post
-	err := r.db.Preload("User").Order("created_at desc").Find(&posts).Error
+	query := r.db.Preload("User").
+		Joins("JOIN post_hashtags h ON h.post_id = posts.id AND h.tag = ?", tag).
+		Order("posts.created_at desc, posts.id desc").
+		Limit(limit)
+	if cursor != nil {
+		query = query.Where("(posts.created_at, posts.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	err := query.Find(&posts).Error
 	return posts, err
 }
 
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReactionRepository struct {
+	db *gorm.DB
+}
+
+func NewReactionRepository(db *gorm.DB) *ReactionRepository {
+	return &ReactionRepository{db: db}
+}
+
+// ApplyBatch inserts and deletes reactions in a single transaction
+func (r *ReactionRepository) ApplyBatch(added, removed []*model.Reaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(added) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&added).Error; err != nil {
+				return err
+			}
+		}
+		for _, reaction := range removed {
+			err := tx.Where("target_type = ? AND target_id = ? AND user_id = ? AND kind = ?",
+				reaction.TargetType, reaction.TargetID, reaction.UserID, reaction.Kind).
+				Delete(&model.Reaction{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetByTargets returns every reaction on the given targets of one type
+func (r *ReactionRepository) GetByTargets(targetType string, targetIDs []uuid.UUID) ([]*model.Reaction, error) {
+	var reactions []*model.Reaction
+	if len(targetIDs) == 0 {
+		return reactions, nil
+	}
+	err := r.db.Where("target_type = ? AND target_id IN ?", targetType, targetIDs).Find(&reactions).Error
+	return reactions, err
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"strings"
+
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// searchConfig is the text search configuration used both to build the
+// search documents and to parse queries; the two must match
+const searchConfig = "english"
+
+// headlineOptions controls the snippets returned with post and message matches
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// escapedBody is the body column HTML-escaped, so the snippets built from it
+// are safe to render as HTML with only the highlight tags live
+const escapedBody = `replace(replace(replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type SearchRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// updatePostSearch rebuilds the search document of a post from its caption
+func updatePostSearch(tx *gorm.DB, postID uuid.UUID) error {
+	return tx.Exec("UPDATE posts SET search_vector = to_tsvector(CAST(? AS regconfig), coalesce(caption, '')) WHERE id = ?",
+		searchConfig, postID).Error
+}
+
+// updateMessageSearch rebuilds the search document of a message from its text
+func updateMessageSearch(tx *gorm.DB, messageID uuid.UUID) error {
+	return tx.Exec("UPDATE messages SET search_vector = to_tsvector(CAST(? AS regconfig), message) WHERE id = ?",
+		searchConfig, messageID).Error
+}
+
+// BackfillSearch builds the search documents of rows stored before search existed
+func (r *SearchRepository) BackfillSearch() error {
+	err := r.db.Exec("UPDATE posts SET search_vector = to_tsvector(CAST(? AS regconfig), coalesce(caption, '')) WHERE search_vector IS NULL",
+		searchConfig).Error
+	if err != nil {
+		return err
+	}
+	return r.db.Exec("UPDATE messages SET search_vector = to_tsvector(CAST(? AS regconfig), message) WHERE search_vector IS NULL",
+		searchConfig).Error
+}
+
+// SearchPosts returns up to limit live posts matching a web-style query (quoted
+// phrases, "or", -exclusions), best match first, strictly after the cursor
+func (r *SearchRepository) SearchPosts(query string, cursor *model.SearchCursor, limit int) ([]*model.SearchHit, error) {
+	return r.searchDocuments(`
+		SELECT p.id, p.caption AS body, q.query, ts_rank_cd(p.search_vector, q.query) AS rank
+		FROM posts p, websearch_to_tsquery(CAST(? AS regconfig), ?) AS q(query)
+		WHERE p.search_vector @@ q.query AND p.deleted_at IS NULL`,
+		query, cursor, limit)
+}
+
+// SearchMessages returns up to limit live messages on live posts matching a
+// web-style query, best match first, strictly after the cursor
+func (r *SearchRepository) SearchMessages(query string, cursor *model.SearchCursor, limit int) ([]*model.SearchHit, error) {
+	return r.searchDocuments(`
+		SELECT m.id, m.message AS body, q.query, ts_rank_cd(m.search_vector, q.query) AS rank
+		FROM messages m
+		JOIN posts p ON p.id = m.post_id AND p.deleted_at IS NULL,
+		websearch_to_tsquery(CAST(? AS regconfig), ?) AS q(query)
+		WHERE m.search_vector @@ q.query AND m.deleted_at IS NULL`,
+		query, cursor, limit)
+}
+
+// searchDocuments pages through the matches selected by matches, which yields
+// id, body, query and rank, and highlights the escaped body of each returned match
+func (r *SearchRepository) searchDocuments(matches, query string, cursor *model.SearchCursor, limit int) ([]*model.SearchHit, error) {
+	args := []interface{}{searchConfig, headlineOptions, searchConfig, query}
+	sql := "SELECT id, rank, ts_headline(CAST(? AS regconfig), " + escapedBody + ", query, ?) AS snippet FROM (" + matches + ") s"
+	if cursor != nil {
+		sql += " WHERE (rank, id) < (?, ?)"
+		args = append(args, cursor.Rank, cursor.ID)
+	}
+	sql += " ORDER BY rank DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	var hits []*model.SearchHit
+	err := r.db.Raw(sql, args...).Scan(&hits).Error
+	return hits, err
+}
+
+// SearchUsers returns up to limit users whose username starts with or is
+// similar to the query (trigram similarity), best match first, strictly after
+// the cursor. Prefix matches always rank above fuzzy ones.
+func (r *SearchRepository) SearchUsers(query string, cursor *model.SearchCursor, limit int) ([]*model.SearchHit, error) {
+	prefix := likeEscaper.Replace(query) + "%"
+	args := []interface{}{query, prefix, query, prefix}
+	sql := `
+		SELECT id, rank FROM (
+			SELECT id, similarity(username, ?) + CASE WHEN username ILIKE ? THEN 1 ELSE 0 END AS rank
+			FROM users
+			WHERE username % ? OR username ILIKE ?
+		) s`
+	if cursor != nil {
+		sql += " WHERE (rank, id) < (?, ?)"
+		args = append(args, cursor.Rank, cursor.ID)
+	}
+	sql += " ORDER BY rank DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	var hits []*model.SearchHit
+	err := r.db.Raw(sql, args...).Scan(&hits).Error
+	return hits, err
+}
@@ -54,3 +54,13 @@ func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
 	}
 	return &user, nil
 }
+
+// GetByIDs returns the users with the given IDs, in no particular order
+func (r *UserRepository) GetByIDs(ids []uuid.UUID) ([]*model.User, error) {
+	var users []*model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,503 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"social-media-app/internal/metrics"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache lookup results reported to metrics
+const (
+	cacheHit       = "hit"
+	cacheMiss      = "miss"
+	cacheCoalesced = "coalesced"
+)
+
+const (
+	// cacheLockPollInterval is how often an instance waiting on another's load checks for the entry
+	cacheLockPollInterval = 25 * time.Millisecond
+	// emptyCacheTTL is how long empty and not-found results are cached, so
+	// lookups of missing data cannot bypass the cache yet new data shows up quickly
+	emptyCacheTTL = 30 * time.Second
+	// cacheGenerationTTL keeps a key's generation well beyond any load in progress
+	cacheGenerationTTL = time.Hour
+	// cacheInvalidationChannel carries invalidated keys to every instance's L1
+	cacheInvalidationChannel = "cache:invalidate"
+)
+
+// Cache tiers reported to metrics
+const (
+	tierL1    = "l1"
+	tierRedis = "redis"
+)
+
+// ErrCacheMiss is returned when a key is not cached at all, as opposed to
+// cached with an empty value
+var ErrCacheMiss = errors.New("cache miss")
+
+// loadFunc loads and encodes a value on a cache miss, reporting whether it is empty
+type loadFunc func(ctx context.Context) (data []byte, empty bool, err error)
+
+// cacheEntry is how fetch stores a value: its encoding plus what early refresh
+// needs, the time the load took and when the entry expires
+type cacheEntry struct {
+	Data      []byte
+	Delta     int64 // milliseconds
+	ExpiresAt int64 // unix milliseconds
+}
+
+// Entries are stored as a format byte, the delta and expiry as big-endian
+// int64s, then the data
+const (
+	cacheEntryFormat     = 1
+	cacheEntryHeaderSize = 17
+)
+
+// newCacheEntry wraps data loaded in delta, to expire after ttl
+func newCacheEntry(data []byte, delta, ttl time.Duration) *cacheEntry {
+	return &cacheEntry{
+		Data:      data,
+		Delta:     delta.Milliseconds(),
+		ExpiresAt: time.Now().Add(ttl).UnixMilli(),
+	}
+}
+
+func (e *cacheEntry) encode() []byte {
+	raw := make([]byte, cacheEntryHeaderSize, cacheEntryHeaderSize+len(e.Data))
+	raw[0] = cacheEntryFormat
+	binary.BigEndian.PutUint64(raw[1:9], uint64(e.Delta))
+	binary.BigEndian.PutUint64(raw[9:17], uint64(e.ExpiresAt))
+	return append(raw, e.Data...)
+}
+
+func decodeCacheEntry(raw []byte) (*cacheEntry, bool) {
+	if len(raw) < cacheEntryHeaderSize || raw[0] != cacheEntryFormat {
+		return nil, false
+	}
+	return &cacheEntry{
+		Data:      raw[cacheEntryHeaderSize:],
+		Delta:     int64(binary.BigEndian.Uint64(raw[1:9])),
+		ExpiresAt: int64(binary.BigEndian.Uint64(raw[9:17])),
+	}, true
+}
+
+// releaseLockScript deletes a lock only if it is still held by the given token
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// setEntryScript stores an entry only if the key's generation is unchanged, so
+// a load that raced with an invalidation cannot store the data it replaced
+var setEntryScript = redis.NewScript(`
+if (redis.call("GET", KEYS[2]) or "") ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+func cacheLockKey(key string) string {
+	return "lock:" + key
+}
+
+// cacheGenerationKey counts the invalidations of a key
+func cacheGenerationKey(key string) string {
+	return "gen:" + key
+}
+
+// flightGroup runs at most one call per key at a time; concurrent callers of
+// the same key wait for it and share its result
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// do runs fn, or waits for the call already running for key. shared reports
+// whether the result came from another caller's call. fn runs in the
+// background, so a caller whose ctx is done stops waiting without failing
+// the others.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) (data []byte, err error, shared bool) {
+	call, leader := g.join(key)
+	if leader {
+		go g.run(key, call, fn)
+	}
+
+	select {
+	case <-call.done:
+		return call.data, call.err, !leader
+	case <-ctx.Done():
+		return nil, ctx.Err(), !leader
+	}
+}
+
+// start runs fn in the background unless a call for key is already running
+func (g *flightGroup) start(key string, fn func() ([]byte, error)) {
+	if call, leader := g.join(key); leader {
+		go g.run(key, call, fn)
+	}
+}
+
+func (g *flightGroup) join(key string) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+func (g *flightGroup) run(key string, call *flightCall, fn func() ([]byte, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.data, call.err = fn()
+}
+
+// cacheKey builds the key of an entry, prefixed with the configured key
+// version and the codec so entries from an older schema or another encoding
+// are never read
+func (s *RedisService) cacheKey(namespace, id string) string {
+	return s.cfg.KeyVersion + ":" + s.codec.Name() + ":" + namespace + ":" + id
+}
+
+// fetch returns a cached value, loading and caching it on a miss.
+// Concurrent misses for a key share one load per instance, and with the cache
+// lock enabled one load across instances. Hits may trigger a background
+// refresh before the entry expires, more likely the nearer expiry is and the
+// slower the load (XFetch), so hot keys are rarely recomputed under a stampede.
+// Loads run detached from ctx's cancellation, as their result is shared.
+func (s *RedisService) fetch(ctx context.Context, cache, key string, ttl time.Duration, load loadFunc) ([]byte, error) {
+	loadCtx := context.WithoutCancel(ctx)
+
+	entry, err := s.lookup(ctx, key)
+	if err == nil {
+		metrics.IncrementCacheRequests(cache, cacheHit)
+		if s.shouldRefreshEarly(entry) {
+			metrics.IncrementCacheEarlyRefreshes(cache)
+			s.flight.start("refresh:"+key, func() ([]byte, error) {
+				data, _, err := s.load(loadCtx, key, ttl, load, false)
+				if err != nil {
+					log.Printf("Error refreshing cache %s: %v", key, err)
+				}
+				return data, err
+			})
+		}
+		return entry.Data, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var loadedElsewhere atomic.Bool
+	data, err, shared := s.flight.do(ctx, key, func() ([]byte, error) {
+		data, elsewhere, err := s.load(loadCtx, key, ttl, load, true)
+		loadedElsewhere.Store(elsewhere)
+		return data, err
+	})
+	if shared || loadedElsewhere.Load() {
+		metrics.IncrementCacheRequests(cache, cacheCoalesced)
+	} else {
+		metrics.IncrementCacheRequests(cache, cacheMiss)
+	}
+	return data, err
+}
+
+// lookup reads an entry from the in-process L1, then from Redis, copying Redis hits into L1
+func (s *RedisService) lookup(ctx context.Context, key string) (*cacheEntry, error) {
+	if s.l1 != nil {
+		entry, ok := s.l1.get(key)
+		metrics.ObserveCacheTierLookup(tierL1, ok)
+		if ok {
+			return entry, nil
+		}
+	}
+
+	version := s.l1.currentVersion()
+	entry, err := s.getEntry(ctx, key)
+	metrics.ObserveCacheTierLookup(tierRedis, err == nil)
+	if err != nil {
+		return nil, err
+	}
+	s.l1.set(key, entry, version)
+	return entry, nil
+}
+
+// load runs a loader and caches its result. With the cache lock enabled and
+// another instance already loading, it waits for that instance's entry instead
+// (elsewhere is true), or gives up at once if wait is false. If the entry does
+// not appear in time, it loads anyway.
+func (s *RedisService) load(ctx context.Context, key string, ttl time.Duration, load loadFunc, wait bool) (data []byte, elsewhere bool, err error) {
+	if s.cfg.Lock {
+		token := uuid.NewString()
+		locked, err := s.acquireLock(ctx, key, token)
+		switch {
+		case err != nil:
+			log.Printf("Error locking cache %s: %v", key, err)
+		case locked:
+			defer s.releaseLock(ctx, key, token)
+		case !wait:
+			return nil, false, nil
+		default:
+			if entry := s.waitForEntry(ctx, key); entry != nil {
+				return entry.Data, true, nil
+			}
+		}
+	}
+
+	version := s.l1.currentVersion()
+	generation, err := s.client.Get(ctx, cacheGenerationKey(key)).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Error reading cache generation %s: %v", key, err)
+	}
+
+	start := time.Now()
+	data, empty, err := load(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if empty {
+		ttl = emptyCacheTTL
+	}
+	entry, stored, err := s.setEntry(ctx, key, generation, data, time.Since(start), ttl)
+	if err != nil {
+		log.Printf("Error writing cache %s: %v", key, err)
+	} else if stored {
+		s.l1.set(key, entry, version)
+	}
+	return data, false, nil
+}
+
+// loadManyFunc loads and encodes the values of the keys at the given indexes
+// after a batch miss; empty[i] reports whether data[i] is an empty result
+type loadManyFunc func(ctx context.Context, missing []int) (data [][]byte, empty []bool, err error)
+
+// fetchMany is fetch for several keys: hits come from L1 and a single MGET,
+// and all misses are loaded with one call. Batches skip the stampede
+// protection of fetch, which would cost a round trip per key.
+func (s *RedisService) fetchMany(ctx context.Context, cache string, keys []string, ttl time.Duration, load loadManyFunc) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	version := s.l1.currentVersion()
+
+	var remote []int
+	for i, key := range keys {
+		if s.l1 != nil {
+			entry, ok := s.l1.get(key)
+			metrics.ObserveCacheTierLookup(tierL1, ok)
+			if ok {
+				values[i] = entry.Data
+				continue
+			}
+		}
+		remote = append(remote, i)
+	}
+
+	var missing []int
+	if len(remote) > 0 {
+		remoteKeys := make([]string, len(remote))
+		for j, i := range remote {
+			remoteKeys[j] = keys[i]
+		}
+		raws, err := s.client.MGet(ctx, remoteKeys...).Result()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Error reading cache %s: %v", cache, err)
+		}
+
+		for j, i := range remote {
+			var entry *cacheEntry
+			var ok bool
+			if raw, isString := entryAt(raws, j).(string); isString {
+				entry, ok = decodeCacheEntry([]byte(raw))
+			}
+			metrics.ObserveCacheTierLookup(tierRedis, ok)
+			if !ok {
+				missing = append(missing, i)
+				continue
+			}
+			values[i] = entry.Data
+			s.l1.set(keys[i], entry, version)
+		}
+	}
+
+	for range len(keys) - len(missing) {
+		metrics.IncrementCacheRequests(cache, cacheHit)
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	for range missing {
+		metrics.IncrementCacheRequests(cache, cacheMiss)
+	}
+
+	generationKeys := make([]string, len(missing))
+	for j, i := range missing {
+		generationKeys[j] = cacheGenerationKey(keys[i])
+	}
+	generations, err := s.client.MGet(ctx, generationKeys...).Result()
+	if err != nil {
+		log.Printf("Error reading cache generations %s: %v", cache, err)
+	}
+
+	start := time.Now()
+	data, empty, err := load(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	delta := time.Since(start)
+
+	pipe := s.client.Pipeline()
+	entries := make([]*cacheEntry, len(missing))
+	cmds := make([]*redis.Cmd, len(missing))
+	for j, i := range missing {
+		values[i] = data[j]
+
+		entryTTL := ttl
+		if empty[j] {
+			entryTTL = emptyCacheTTL
+		}
+		entries[j] = newCacheEntry(data[j], delta, entryTTL)
+		generation, _ := entryAt(generations, j).(string)
+		cmds[j] = setEntryScript.Eval(ctx, pipe, []string{keys[i], cacheGenerationKey(keys[i])},
+			generation, entries[j].encode(), entryTTL.Milliseconds())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error writing cache %s: %v", cache, err)
+		return values, nil
+	}
+	for j, i := range missing {
+		if n, _ := cmds[j].Int(); n == 1 {
+			s.l1.set(keys[i], entries[j], version)
+		}
+	}
+	return values, nil
+}
+
+// entryAt returns values[i], or nil if a failed read left values short
+func entryAt(values []interface{}, i int) interface{} {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
+
+// shouldRefreshEarly implements XFetch: refresh when now - delta*beta*ln(rand)
+// reaches the expiry, where delta is how long the last load took
+func (s *RedisService) shouldRefreshEarly(entry *cacheEntry) bool {
+	if s.cfg.EarlyRefreshBeta <= 0 {
+		return false
+	}
+	gap := float64(entry.Delta) * s.cfg.EarlyRefreshBeta * -math.Log(rand.Float64())
+	return float64(time.Now().UnixMilli())+gap >= float64(entry.ExpiresAt)
+}
+
+func (s *RedisService) getEntry(ctx context.Context, key string) (*cacheEntry, error) {
+	raw, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Values not written by fetch count as misses
+	entry, ok := decodeCacheEntry(raw)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return entry, nil
+}
+
+// setEntry stores an entry unless the key was invalidated since generation
+// was read; stored reports which happened
+func (s *RedisService) setEntry(ctx context.Context, key, generation string, data []byte, delta, ttl time.Duration) (entry *cacheEntry, stored bool, err error) {
+	entry = newCacheEntry(data, delta, ttl)
+	keys := []string{key, cacheGenerationKey(key)}
+	n, err := setEntryScript.Run(ctx, s.client, keys, generation, entry.encode(), ttl.Milliseconds()).Int()
+	return entry, n == 1, err
+}
+
+// invalidate removes a fetch-managed entry and bumps its generation, so loads
+// already in progress do not store what they read before the change, then
+// tells every instance to drop its L1 copy. It runs even if ctx is cancelled,
+// since the write it follows has already happened.
+func (s *RedisService) invalidate(ctx context.Context, key string) error {
+	ctx = context.WithoutCancel(ctx)
+	s.l1.remove(key)
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.Incr(ctx, cacheGenerationKey(key))
+	pipe.Expire(ctx, cacheGenerationKey(key), cacheGenerationTTL)
+	pipe.Publish(ctx, cacheInvalidationChannel, key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RunCacheInvalidation evicts L1 entries invalidated by any instance. Messages
+// missed while reconnecting to Redis leave entries stale for at most the L1 TTL.
+func (s *RedisService) RunCacheInvalidation() {
+	if s.l1 == nil {
+		return
+	}
+
+	pubsub := s.client.Subscribe(context.Background(), cacheInvalidationChannel)
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		s.l1.remove(msg.Payload)
+	}
+}
+
+// waitForEntry polls for an entry another instance is loading, returning nil after cfg.LockWait
+func (s *RedisService) waitForEntry(ctx context.Context, key string) *cacheEntry {
+	deadline := time.Now().Add(s.cfg.LockWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(cacheLockPollInterval)
+		if entry, err := s.getEntry(ctx, key); err == nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (s *RedisService) acquireLock(ctx context.Context, key, token string) (bool, error) {
+	return s.client.SetNX(ctx, cacheLockKey(key), token, s.cfg.LockTTL).Result()
+}
+
+func (s *RedisService) releaseLock(ctx context.Context, key, token string) {
+	if err := releaseLockScript.Run(ctx, s.client, []string{cacheLockKey(key)}, token).Err(); err != nil {
+		log.Printf("Error unlocking cache %s: %v", key, err)
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"social-media-app/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testCacheConfig(codec string) config.CacheConfig {
+	return config.CacheConfig{
+		L1Size:     100,
+		L1TTL:      time.Minute,
+		KeyVersion: "v1",
+		Codec:      codec,
+	}
+}
+
+// connectTestRedisService returns a service on mr, one per simulated instance
+func connectTestRedisService(t *testing.T, mr *miniredis.Miniredis, cfg config.CacheConfig) *RedisService {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisService(client, cfg)
+}
+
+// newTestRedisService returns a service backed by an in-memory Redis
+func newTestRedisService(t *testing.T, codec string) (*RedisService, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return connectTestRedisService(t, mr, testCacheConfig(codec)), mr
+}
+
+// waitUntil polls cond until it holds, failing the test after a second
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (g *flightGroup) running(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+func TestFlightGroupSharesResult(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	var leaders atomic.Int32
+	var joined atomic.Int32
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			joined.Add(1)
+			data, err, shared := g.do(context.Background(), "key", fn)
+			if err != nil || string(data) != "value" {
+				t.Errorf("got %q, %v", data, err)
+			}
+			if !shared {
+				leaders.Add(1)
+			}
+		}()
+	}
+
+	waitUntil(t, func() bool { return joined.Load() == callers && g.running("key") })
+	time.Sleep(20 * time.Millisecond) // let every caller reach do
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+	if n := leaders.Load(); n != 1 {
+		t.Errorf("%d callers ran the call, want 1", n)
+	}
+	if g.running("key") {
+		t.Error("finished call still registered")
+	}
+}
+
+func TestFlightGroupCancelledWaiterLeavesLeaderRunning(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	// The caller that starts the call gives up first
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err, _ := g.do(leaderCtx, "key", fn)
+		leaderDone <- err
+	}()
+	waitUntil(t, func() bool { return g.running("key") })
+
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	waiterDone := make(chan error, 1)
+	go func() {
+		_, err, _ := g.do(waiterCtx, "key", fn)
+		waiterDone <- err
+	}()
+
+	g.mu.Lock()
+	call := g.calls["key"]
+	g.mu.Unlock()
+
+	cancelLeader()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled leader got %v", err)
+	}
+	cancelWaiter()
+	if err := <-waiterDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled waiter got %v", err)
+	}
+	if !g.running("key") {
+		t.Fatal("cancelling callers stopped the call")
+	}
+
+	close(release)
+	<-call.done
+	if string(call.data) != "value" || call.err != nil {
+		t.Fatalf("call finished with %q, %v", call.data, call.err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+}
+
+func TestCacheEntryRoundTrip(t *testing.T) {
+	entry := &cacheEntry{Data: []byte(`{"id":1}`), Delta: 42, ExpiresAt: time.Now().Add(time.Minute).UnixMilli()}
+
+	decoded, ok := decodeCacheEntry(entry.encode())
+	if !ok {
+		t.Fatal("encoded entry not decoded")
+	}
+	if !bytes.Equal(decoded.Data, entry.Data) || decoded.Delta != entry.Delta || decoded.ExpiresAt != entry.ExpiresAt {
+		t.Fatalf("got %+v, want %+v", decoded, entry)
+	}
+
+	empty, ok := decodeCacheEntry((&cacheEntry{ExpiresAt: entry.ExpiresAt}).encode())
+	if !ok || len(empty.Data) != 0 {
+		t.Fatalf("empty entry decoded as %+v, %v", empty, ok)
+	}
+
+	for _, raw := range [][]byte{nil, entry.encode()[:cacheEntryHeaderSize-1], []byte(`{"data":"legacy json"}`)} {
+		if _, ok := decodeCacheEntry(raw); ok {
+			t.Errorf("decoded invalid entry %q", raw)
+		}
+	}
+}
+
+func TestShouldRefreshEarly(t *testing.T) {
+	s := &RedisService{cfg: config.CacheConfig{EarlyRefreshBeta: 1}}
+	now := time.Now().UnixMilli()
+
+	if s.shouldRefreshEarly(&cacheEntry{Delta: 1, ExpiresAt: now + time.Hour.Milliseconds()}) {
+		t.Error("fast load refreshed an hour before expiry")
+	}
+	if !s.shouldRefreshEarly(&cacheEntry{Delta: 1, ExpiresAt: now - 1}) {
+		t.Error("expired entry not refreshed")
+	}
+
+	s.cfg.EarlyRefreshBeta = 0
+	if s.shouldRefreshEarly(&cacheEntry{Delta: 1, ExpiresAt: now - 1}) {
+		t.Error("refreshed with early refresh disabled")
+	}
+}
+
+// slowLoad returns a loader that takes a while and counts its calls
+func slowLoad(loads *atomic.Int32, value string) loadFunc {
+	return func(ctx context.Context) ([]byte, bool, error) {
+		loads.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return []byte(value), false, nil
+	}
+}
+
+func TestFetchCoalescesMisses(t *testing.T) {
+	s, _ := newTestRedisService(t, "json")
+	ctx := context.Background()
+	key := s.cacheKey("post", "1")
+
+	var loads atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, err := s.fetch(ctx, "post", key, time.Minute, slowLoad(&loads, "value")); err != nil || string(data) != "value" {
+				t.Errorf("got %q, %v", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loaded %d times, want 1", n)
+	}
+
+	if data, err := s.fetch(ctx, "post", key, time.Minute, slowLoad(&loads, "other")); err != nil || string(data) != "value" {
+		t.Fatalf("hit: got %q, %v", data, err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatal("hit called the loader")
+	}
+}
+
+func TestFetchLockCoalescesAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testCacheConfig("json")
+	cfg.Lock, cfg.LockTTL, cfg.LockWait = true, time.Second, time.Second
+	instances := []*RedisService{connectTestRedisService(t, mr, cfg), connectTestRedisService(t, mr, cfg)}
+	key := instances[0].cacheKey("post", "1")
+
+	var loads atomic.Int32
+	var wg sync.WaitGroup
+	for _, s := range instances {
+		wg.Add(1)
+		go func(s *RedisService) {
+			defer wg.Done()
+			if data, err := s.fetch(context.Background(), "post", key, time.Minute, slowLoad(&loads, "value")); err != nil || string(data) != "value" {
+				t.Errorf("got %q, %v", data, err)
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loaded %d times across instances, want 1", n)
+	}
+	if mr.Exists(cacheLockKey(key)) {
+		t.Fatal("lock not released")
+	}
+}
+
+func TestFetchTreatsForeignValuesAsMisses(t *testing.T) {
+	s, mr := newTestRedisService(t, "json")
+	key := s.cacheKey("post", "1")
+
+	// A value not written by fetch is not an entry
+	mr.Set(key, `{"id":"1"}`)
+
+	var loads atomic.Int32
+	if data, err := s.fetch(context.Background(), "post", key, time.Minute, slowLoad(&loads, "value")); err != nil || string(data) != "value" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loaded %d times, want 1", n)
+	}
+}
+
+func TestFetchCachesEmptyResultsBriefly(t *testing.T) {
+	s, mr := newTestRedisService(t, "json")
+	key := s.cacheKey("post_messages", "1")
+
+	data, err := s.fetch(context.Background(), "post_messages", key, time.Hour, func(ctx context.Context) ([]byte, bool, error) {
+		return []byte("[]"), true, nil
+	})
+	if err != nil || string(data) != "[]" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if ttl := mr.TTL(key); ttl <= 0 || ttl > emptyCacheTTL {
+		t.Fatalf("empty result cached for %v, want at most %v", ttl, emptyCacheTTL)
+	}
+}
+
+func TestFetchDropsLoadRacingInvalidation(t *testing.T) {
+	s, mr := newTestRedisService(t, "json")
+	ctx := context.Background()
+	key := s.cacheKey("post", "1")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	result := make(chan []byte, 1)
+	go func() {
+		data, err := s.fetch(ctx, "post", key, time.Minute, func(ctx context.Context) ([]byte, bool, error) {
+			close(started)
+			<-release
+			return []byte("stale"), false, nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		result <- data
+	}()
+
+	<-started
+	if err := s.invalidate(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	// The caller still gets what it loaded, but nobody else will
+	if data := <-result; string(data) != "stale" {
+		t.Fatalf("got %q", data)
+	}
+	if mr.Exists(key) {
+		t.Fatal("load that raced an invalidation was stored in Redis")
+	}
+	if _, ok := s.l1.get(key); ok {
+		t.Fatal("load that raced an invalidation was stored in L1")
+	}
+
+	var loads atomic.Int32
+	for i := 0; i < 2; i++ {
+		data, err := s.fetch(ctx, "post", key, time.Minute, slowLoad(&loads, "fresh"))
+		if err != nil || string(data) != "fresh" {
+			t.Fatalf("got %q, %v", data, err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loaded %d times, want 1", n)
+	}
+	if !mr.Exists(key) {
+		t.Fatal("fresh load not stored")
+	}
+}
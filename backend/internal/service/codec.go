@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Codec encodes values stored in the cache
+type Codec interface {
+	// Name identifies the encoding in cache keys, so entries written with
+	// another codec are never decoded with this one
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec is more compact and faster to decode than JSON. It honours
+// the models' json tags, so both codecs cache the same fields.
+type MsgpackCodec struct{}
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.TypeInfos = codec.NewTypeInfos([]string{"json"})
+	return h
+}()
+
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(v)
+	return data, err
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+// NewCodec returns the codec with the given name, falling back to JSON
+func NewCodec(name string) Codec {
+	switch name {
+	case "json":
+		return JSONCodec{}
+	case "msgpack":
+		return MsgpackCodec{}
+	}
+	log.Printf("Unknown cache codec %q, using json", name)
+	return JSONCodec{}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+)
+
+var testCodecs = []Codec{JSONCodec{}, MsgpackCodec{}}
+
+func testPost() *model.Post {
+	userID := uuid.New()
+	created := time.Date(2026, 3, 14, 15, 9, 26, 535897000, time.UTC)
+	return &model.Post{
+		ID:       uuid.New(),
+		UserID:   userID,
+		ImageURL: "https://example.com/image.jpg",
+		Caption:  "hello @ana #go",
+		Entities: &model.Entities{
+			Mentions: []model.Mention{{UserID: uuid.New(), Username: "ana", Start: 6, End: 10}},
+			Hashtags: []model.Hashtag{{Tag: "go", Start: 11, End: 14}},
+		},
+		CreatedAt:    created,
+		UpdatedAt:    created.Add(time.Minute),
+		Reactions:    map[string]int64{"like": 3},
+		SearchVector: "'hello':1",
+		User: model.User{
+			ID:           userID,
+			Username:     "bob",
+			PasswordHash: "secret",
+			CreatedAt:    created,
+		},
+		Messages: []model.Message{},
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range testCodecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			post := testPost()
+			data, err := codec.Marshal(post)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var decoded *model.Post
+			if err := codec.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded == nil {
+				t.Fatal("decoded nil post")
+			}
+
+			if !decoded.CreatedAt.Equal(post.CreatedAt) || !decoded.UpdatedAt.Equal(post.UpdatedAt) || !decoded.User.CreatedAt.Equal(post.User.CreatedAt) {
+				t.Errorf("times changed: %v, %v", decoded.CreatedAt, decoded.UpdatedAt)
+			}
+
+			// Fields hidden from JSON are not cached by either codec
+			if decoded.SearchVector != "" || decoded.User.PasswordHash != "" {
+				t.Error("json:\"-\" field was encoded")
+			}
+
+			// What the API serves from the cache is what it would serve from the database
+			got, _ := json.Marshal(decoded)
+			want, _ := json.Marshal(post)
+			if string(got) != string(want) {
+				t.Errorf("got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCodecRoundTripNilPost(t *testing.T) {
+	for _, codec := range testCodecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			var post *model.Post
+			data, err := codec.Marshal(post)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var decoded *model.Post
+			if err := codec.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != nil {
+				t.Fatalf("nil post decoded as %+v", decoded)
+			}
+
+			// A reused destination is reset, not left holding an older value
+			decoded = testPost()
+			if err := codec.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != nil {
+				t.Fatalf("nil post decoded over a value as %+v", decoded)
+			}
+		})
+	}
+}
+
+// Not-found results go through the typed cache as a nil post and come back as one
+func TestPostCacheCachesNotFound(t *testing.T) {
+	for _, codec := range testCodecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			s, _ := newTestRedisService(t, codec.Name())
+			s.cfg.PostTTL = time.Minute
+			posts := newPostCache(s)
+			ctx := context.Background()
+
+			missing, found := uuid.New(), testPost()
+			loads := 0
+			load := func(id uuid.UUID) func(context.Context) (*model.Post, bool, error) {
+				return func(context.Context) (*model.Post, bool, error) {
+					loads++
+					if id == found.ID {
+						return found, false, nil
+					}
+					return nil, true, nil
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				post, err := posts.Get(ctx, missing, load(missing))
+				if err != nil || post != nil {
+					t.Fatalf("missing post: got %+v, %v", post, err)
+				}
+				post, err = posts.Get(ctx, found.ID, load(found.ID))
+				if err != nil || post == nil || post.ID != found.ID || post.Caption != found.Caption {
+					t.Fatalf("found post: got %+v, %v", post, err)
+				}
+			}
+			if loads != 2 {
+				t.Fatalf("loaded %d times, want 2", loads)
+			}
+
+			values, err := posts.GetMany(ctx, []uuid.UUID{found.ID, missing, uuid.New()}, func(context.Context, []uuid.UUID) (map[uuid.UUID]*model.Post, error) {
+				return map[uuid.UUID]*model.Post{}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values[0] == nil || values[0].ID != found.ID || values[1] != nil || values[2] != nil {
+				t.Fatalf("got %+v", values)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrNoParticipants       = errors.New("a conversation needs at least one other participant")
+	ErrTooManyParticipants  = fmt.Errorf("a conversation can have at most %d participants", model.MaxConversationParticipants)
+)
+
+type ConversationService struct {
+	repo     *repository.ConversationRepository
+	userRepo *repository.UserRepository
+}
+
+func NewConversationService(repo *repository.ConversationRepository, userRepo *repository.UserRepository) *ConversationService {
+	return &ConversationService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// directKey identifies the 1:1 conversation of two users regardless of who started it
+func directKey(a, b uuid.UUID) string {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+// CreateConversation starts a conversation between the creator and the given
+// users. A 1:1 conversation that already exists is returned instead of
+// creating a second one; created reports which happened.
+func (s *ConversationService) CreateConversation(creatorID uuid.UUID, req *model.CreateConversationRequest) (conversation *model.Conversation, created bool, err error) {
+	seen := map[uuid.UUID]bool{creatorID: true}
+	var others []uuid.UUID
+	for _, id := range req.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		return nil, false, ErrNoParticipants
+	}
+	if len(others)+1 > model.MaxConversationParticipants {
+		return nil, false, ErrTooManyParticipants
+	}
+
+	users, err := s.userRepo.GetByIDs(others)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(users) != len(others) {
+		return nil, false, ErrUserNotFound
+	}
+
+	conversation = &model.Conversation{LastMessageAt: time.Now()}
+	if len(others) == 1 {
+		key := directKey(creatorID, others[0])
+		existing, err := s.repo.GetByDirectKey(key)
+		if err != nil || existing != nil {
+			return existing, false, err
+		}
+		conversation.DirectKey = &key
+	}
+
+	for _, id := range append([]uuid.UUID{creatorID}, others...) {
+		conversation.Participants = append(conversation.Participants, model.ConversationParticipant{UserID: id})
+	}
+
+	if err := s.repo.Create(conversation); err != nil {
+		// Lost a race to create the same 1:1 conversation
+		if conversation.DirectKey != nil {
+			if existing, _ := s.repo.GetByDirectKey(*conversation.DirectKey); existing != nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, err
+	}
+
+	conversation, err = s.repo.GetByID(conversation.ID)
+	return conversation, true, err
+}
+
+// GetConversation returns a conversation the user takes part in. Other
+// users' conversations are reported as not found.
+func (s *ConversationService) GetConversation(conversationID, userID uuid.UUID) (*model.Conversation, error) {
+	conversation, err := s.repo.GetByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation == nil || !conversation.HasParticipant(userID) {
+		return nil, ErrConversationNotFound
+	}
+	return conversation, nil
+}
+
+// ListConversations returns a page of the user's conversations, most recently
+// active first, with their latest message and the user's unread count
+func (s *ConversationService) ListConversations(userID uuid.UUID, cursor *model.Cursor, limit int) (*model.ConversationPage, error) {
+	limit = model.ClampPageSize(limit)
+
+	conversations, err := s.repo.GetPageForUser(userID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.ConversationPage{Conversations: conversations}
+	if len(conversations) > limit {
+		page.Conversations = conversations[:limit]
+		page.NextCursor = page.Conversations[limit-1].Cursor().Encode()
+	}
+
+	ids := make([]uuid.UUID, 0, len(page.Conversations))
+	for _, conversation := range page.Conversations {
+		ids = append(ids, conversation.ID)
+	}
+
+	latest, err := s.repo.GetLatestMessages(ids)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.repo.GetUnreadCounts(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, conversation := range page.Conversations {
+		conversation.LastMessage = latest[conversation.ID]
+		conversation.UnreadCount = unread[conversation.ID]
+	}
+
+	return page, nil
+}
+
+// SendMessage posts a message to a conversation the sender takes part in and
+// returns it along with the conversation, whose participants should be notified.
+// The sender's own read position moves to the new message.
+func (s *ConversationService) SendMessage(conversationID, senderID uuid.UUID, req *model.SendDirectMessageRequest) (*model.DirectMessage, *model.Conversation, error) {
+	conversation, err := s.GetConversation(conversationID, senderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message := &model.DirectMessage{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Message:        req.Message,
+	}
+	if err := s.repo.CreateMessage(message); err != nil {
+		return nil, nil, err
+	}
+
+	for _, participant := range conversation.Participants {
+		if participant.UserID == senderID {
+			message.Sender = participant.User
+		}
+	}
+
+	if _, err := s.repo.MarkRead(conversationID, senderID, message); err != nil {
+		return nil, nil, err
+	}
+
+	return message, conversation, nil
+}
+
+// GetMessages returns a page of a conversation's messages, newest first
+func (s *ConversationService) GetMessages(conversationID, userID uuid.UUID, cursor *model.Cursor, limit int) (*model.DirectMessagePage, error) {
+	if _, err := s.GetConversation(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	limit = model.ClampPageSize(limit)
+	messages, err := s.repo.GetMessages(conversationID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.DirectMessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = page.Messages[limit-1].Cursor().Encode()
+	}
+	return page, nil
+}
+
+// MarkRead moves the user's read position to messageID, or to the latest
+// message when nil. It returns the receipt, or nil if the position did not
+// move, along with the conversation whose participants should be told.
+func (s *ConversationService) MarkRead(conversationID, userID uuid.UUID, messageID *uuid.UUID) (*model.ReadReceipt, *model.Conversation, error) {
+	conversation, err := s.GetConversation(conversationID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var message *model.DirectMessage
+	if messageID != nil {
+		message, err = s.repo.GetMessageByID(*messageID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if message == nil || message.ConversationID != conversationID {
+			return nil, nil, ErrMessageNotFound
+		}
+	} else {
+		latest, err := s.repo.GetLatestMessages([]uuid.UUID{conversationID})
+		if err != nil {
+			return nil, nil, err
+		}
+		if message = latest[conversationID]; message == nil {
+			return nil, conversation, nil
+		}
+	}
+
+	moved, err := s.repo.MarkRead(conversationID, userID, message)
+	if err != nil || !moved {
+		return nil, conversation, err
+	}
+
+	return &model.ReadReceipt{
+		ConversationID: conversationID,
+		UserID:         userID,
+		MessageID:      message.ID,
+		ReadAt:         time.Now(),
+	}, conversation, nil
+}
+
+// CountUnread returns the user's unread direct messages across all conversations
+func (s *ConversationService) CountUnread(userID uuid.UUID) (int64, error) {
+	return s.repo.CountUnread(userID)
+}
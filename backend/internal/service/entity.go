@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/utils"
+)
+
+// maxMentionLookups bounds how many distinct usernames are resolved per text
+const maxMentionLookups = 10
+
+// Number of trending hashtags returned by default and at most
+const (
+	defaultTrendingHashtags = 10
+	maxTrendingHashtags     = 50
+)
+
+// EntityService extracts mentions and hashtags from user text and tracks
+// hashtag usage for trending
+type EntityService struct {
+	userRepo     *repository.UserRepository
+	redisService *RedisService
+}
+
+func NewEntityService(userRepo *repository.UserRepository, redisService *RedisService) *EntityService {
+	return &EntityService{
+		userRepo:     userRepo,
+		redisService: redisService,
+	}
+}
+
+// Extract finds the mentions and hashtags of a text. Mentions of unknown
+// users are left as plain text. Returns nil if there are none.
+func (s *EntityService) Extract(text string) *model.Entities {
+	entities := &model.Entities{}
+
+	users := make(map[string]*model.User)
+	for _, match := range utils.ParseMentions(text) {
+		user, looked := users[match.Value]
+		if !looked {
+			if len(users) == maxMentionLookups {
+				continue
+			}
+			var err error
+			user, err = s.userRepo.GetByUsername(match.Value)
+			if err != nil {
+				log.Printf("Error resolving mention @%s: %v", match.Value, err)
+			}
+			users[match.Value] = user
+		}
+		if user != nil {
+			entities.Mentions = append(entities.Mentions, model.Mention{
+				UserID:   user.ID,
+				Username: user.Username,
+				Start:    match.Start,
+				End:      match.End,
+			})
+		}
+	}
+
+	for _, match := range utils.ParseHashtags(text) {
+		entities.Hashtags = append(entities.Hashtags, model.Hashtag{
+			Tag:   match.Value,
+			Start: match.Start,
+			End:   match.End,
+		})
+	}
+
+	if len(entities.Mentions) == 0 && len(entities.Hashtags) == 0 {
+		return nil
+	}
+	return entities
+}
+
+// RecordUsage counts the hashtags of newly created content towards trending
+func (s *EntityService) RecordUsage(ctx context.Context, entities *model.Entities) {
+	if err := s.redisService.IncrementHashtags(ctx, entities.Tags()); err != nil {
+		log.Printf("Error counting hashtag usage: %v", err)
+	}
+}
+
+// GetTrendingHashtags returns the hashtags used most within the trending window
+func (s *EntityService) GetTrendingHashtags(ctx context.Context, limit int) ([]model.TrendingHashtag, error) {
+	if limit <= 0 {
+		limit = defaultTrendingHashtags
+	} else if limit > maxTrendingHashtags {
+		limit = maxTrendingHashtags
+	}
+
+	entries, err := s.redisService.GetTrendingHashtags(ctx, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	trending := make([]model.TrendingHashtag, 0, len(entries))
+	for _, entry := range entries {
+		tag, _ := entry.Member.(string)
+		trending = append(trending, model.TrendingHashtag{Tag: tag, Count: int64(entry.Score)})
+	}
+	return trending, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sort"
+	"strconv"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// timelineTieSlack is how many extra IDs are read from a timeline so posts
+// sharing the cursor's millisecond can be skipped without shortening the page
+const timelineTieSlack = 10
+
+// FeedService builds home timelines. Posts by regular authors are fanned out on
+// write into per-user Redis sorted sets; posts by authors with at least
+// FanOutThreshold followers are merged in on read.
+type FeedService struct {
+	postRepo        *repository.PostRepository
+	followRepo      *repository.FollowRepository
+	userRepo        *repository.UserRepository
+	redisService    *RedisService
+	reactionService *ReactionService
+	cfg             config.FeedConfig
+}
+
+func NewFeedService(postRepo *repository.PostRepository, followRepo *repository.FollowRepository, userRepo *repository.UserRepository, redisService *RedisService, reactionService *ReactionService, cfg config.FeedConfig) *FeedService {
+	return &FeedService{
+		postRepo:        postRepo,
+		followRepo:      followRepo,
+		userRepo:        userRepo,
+		redisService:    redisService,
+		reactionService: reactionService,
+		cfg:             cfg,
+	}
+}
+
+func timelineScore(post *model.Post) float64 {
+	return float64(post.CreatedAt.UnixMilli())
+}
+
+// FanOutPost pushes a new post into the timelines of its author and the author's followers
+func (s *FeedService) FanOutPost(ctx context.Context, post *model.Post) {
+	author, err := s.userRepo.GetByID(post.UserID)
+	if err != nil || author == nil {
+		log.Printf("Error loading author %s for fan-out: %v", post.UserID, err)
+		return
+	}
+
+	recipients := []string{post.UserID.String()}
+
+	// Popular authors are merged in at read time instead
+	if author.FollowerCount < s.cfg.FanOutThreshold {
+		followerIDs, err := s.followRepo.GetFollowerIDs(post.UserID)
+		if err != nil {
+			log.Printf("Error loading followers of %s for fan-out: %v", post.UserID, err)
+			return
+		}
+		for _, id := range followerIDs {
+			recipients = append(recipients, id.String())
+		}
+	}
+
+	err = s.redisService.AddToTimelines(ctx, recipients, post.ID.String(), timelineScore(post), s.cfg.TimelineSize)
+	if err != nil {
+		log.Printf("Error fanning out post %s: %v", post.ID, err)
+	}
+}
+
+// RemovePost takes a deleted post out of the timelines of its author and the
+// author's followers. Followers are included even for popular authors, who
+// may have crossed the fan-out threshold after the post was pushed.
+func (s *FeedService) RemovePost(ctx context.Context, post *model.Post) {
+	followerIDs, err := s.followRepo.GetFollowerIDs(post.UserID)
+	if err != nil {
+		log.Printf("Error loading followers of %s to remove post %s: %v", post.UserID, post.ID, err)
+		return
+	}
+
+	userIDs := []string{post.UserID.String()}
+	for _, id := range followerIDs {
+		userIDs = append(userIDs, id.String())
+	}
+
+	if err := s.redisService.RemoveFromTimelines(ctx, userIDs, post.ID.String()); err != nil {
+		log.Printf("Error removing post %s from timelines: %v", post.ID, err)
+	}
+}
+
+// OnFollow backfills the followee's recent posts into the follower's timeline
+func (s *FeedService) OnFollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	exists, err := s.redisService.TimelineExists(ctx, followerID.String())
+	if err != nil || !exists {
+		// A missing timeline is rebuilt in full on the next read
+		return err
+	}
+
+	posts, err := s.postRepo.GetPageByUserIDs([]uuid.UUID{followeeID}, nil, s.cfg.TimelineSize)
+	if err != nil {
+		return err
+	}
+
+	return s.redisService.FillTimeline(ctx, followerID.String(), timelineEntries(posts), s.cfg.TimelineSize)
+}
+
+// OnUnfollow removes the followee's posts from the follower's timeline
+func (s *FeedService) OnUnfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	posts, err := s.postRepo.GetPageByUserIDs([]uuid.UUID{followeeID}, nil, s.cfg.TimelineSize)
+	if err != nil {
+		return err
+	}
+
+	postIDs := make([]string, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID.String()
+	}
+
+	return s.redisService.RemoveFromTimeline(ctx, followerID.String(), postIDs)
+}
+
+// GetHomeTimeline returns a page of the user's home timeline, newest first
+func (s *FeedService) GetHomeTimeline(ctx context.Context, userID uuid.UUID, cursor *model.Cursor, limit int) (*model.PostPage, error) {
+	limit = model.ClampPageSize(limit)
+
+	if err := s.ensureTimeline(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// Fan-out-on-write part
+	maxScore := "+inf"
+	if cursor != nil {
+		maxScore = strconv.FormatInt(cursor.CreatedAt.UnixMilli(), 10)
+	}
+	ids, err := s.redisService.GetTimeline(ctx, userID.String(), maxScore, int64(limit+1+timelineTieSlack))
+	if err != nil {
+		return nil, err
+	}
+
+	postIDs := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if postID, err := uuid.Parse(id); err == nil {
+			postIDs = append(postIDs, postID)
+		}
+	}
+
+	posts, err := s.postRepo.GetByIDs(postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop IDs of posts deleted since they were pushed, should a removal have been missed
+	if len(posts) < len(postIDs) {
+		s.pruneTimeline(ctx, userID, postIDs, posts)
+	}
+
+	// Fan-out-on-read part
+	popularIDs, err := s.followRepo.GetPopularFolloweeIDs(userID, s.cfg.FanOutThreshold)
+	if err != nil {
+		return nil, err
+	}
+	popularPosts, err := s.postRepo.GetPageByUserIDs(popularIDs, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := mergeTimeline(append(posts, popularPosts...), cursor, limit)
+	s.reactionService.AttachToPosts(ctx, page.Posts)
+
+	return page, nil
+}
+
+// ensureTimeline rebuilds a missing timeline from the regular authors the user follows
+func (s *FeedService) ensureTimeline(ctx context.Context, userID uuid.UUID) error {
+	exists, err := s.redisService.TimelineExists(ctx, userID.String())
+	if err != nil || exists {
+		return err
+	}
+
+	authorIDs, err := s.followRepo.GetRegularFolloweeIDs(userID, s.cfg.FanOutThreshold)
+	if err != nil {
+		return err
+	}
+	authorIDs = append(authorIDs, userID)
+
+	posts, err := s.postRepo.GetPageByUserIDs(authorIDs, nil, s.cfg.TimelineSize)
+	if err != nil {
+		return err
+	}
+
+	return s.redisService.FillTimeline(ctx, userID.String(), timelineEntries(posts), s.cfg.TimelineSize)
+}
+
+// pruneTimeline removes the IDs that did not load from a user's timeline
+func (s *FeedService) pruneTimeline(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID, posts []*model.Post) {
+	found := make(map[uuid.UUID]bool, len(posts))
+	for _, post := range posts {
+		found[post.ID] = true
+	}
+
+	var missing []string
+	for _, id := range postIDs {
+		if !found[id] {
+			missing = append(missing, id.String())
+		}
+	}
+
+	if err := s.redisService.RemoveFromTimeline(ctx, userID.String(), missing); err != nil {
+		log.Printf("Error pruning timeline of %s: %v", userID, err)
+	}
+}
+
+func timelineEntries(posts []*model.Post) []redis.Z {
+	entries := make([]redis.Z, len(posts))
+	for i, post := range posts {
+		entries[i] = redis.Z{Score: timelineScore(post), Member: post.ID.String()}
+	}
+	return entries
+}
+
+// mergeTimeline sorts and de-duplicates posts, drops those not after the cursor and cuts the page
+func mergeTimeline(posts []*model.Post, cursor *model.Cursor, limit int) *model.PostPage {
+	seen := make(map[uuid.UUID]bool, len(posts))
+	merged := make([]*model.Post, 0, len(posts))
+	for _, post := range posts {
+		if seen[post.ID] || (cursor != nil && !cursorAfter(cursor, post)) {
+			continue
+		}
+		seen[post.ID] = true
+		merged = append(merged, post)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return cursorAfter(merged[i].Cursor(), merged[j])
+	})
+
+	page := &model.PostPage{Posts: merged}
+	if len(merged) > limit {
+		page.Posts = merged[:limit]
+		page.NextCursor = merged[limit-1].Cursor().Encode()
+	}
+	return page
+}
+
+// cursorAfter reports whether post comes strictly after cursor in newest-first order
+func cursorAfter(cursor *model.Cursor, post *model.Post) bool {
+	if !post.CreatedAt.Equal(cursor.CreatedAt) {
+		return post.CreatedAt.Before(cursor.CreatedAt)
+	}
+	return bytes.Compare(post.ID[:], cursor.ID[:]) < 0
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrCannotFollowSelf = errors.New("you cannot follow yourself")
+)
+
+type FollowService struct {
+	repo                *repository.FollowRepository
+	userRepo            *repository.UserRepository
+	feedService         *FeedService
+	notificationService *NotificationService
+}
+
+func NewFollowService(repo *repository.FollowRepository, userRepo *repository.UserRepository, feedService *FeedService, notificationService *NotificationService) *FollowService {
+	return &FollowService{
+		repo:                repo,
+		userRepo:            userRepo,
+		feedService:         feedService,
+		notificationService: notificationService,
+	}
+}
+
+func (s *FollowService) Follow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	if followerID == followeeID {
+		return ErrCannotFollowSelf
+	}
+
+	followee, err := s.userRepo.GetByID(followeeID)
+	if err != nil {
+		return err
+	}
+	if followee == nil {
+		return ErrUserNotFound
+	}
+
+	created, err := s.repo.Create(followerID, followeeID)
+	if err != nil || !created {
+		return err
+	}
+
+	// The timeline is a cache; a failed backfill only delays the followee's older posts
+	if err := s.feedService.OnFollow(ctx, followerID, followeeID); err != nil {
+		log.Printf("Error backfilling timeline of %s: %v", followerID, err)
+	}
+
+	s.notificationService.NotifyFollow(ctx, followeeID, followerID)
+
+	return nil
+}
+
+func (s *FollowService) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	deleted, err := s.repo.Delete(followerID, followeeID)
+	if err != nil || !deleted {
+		return err
+	}
+
+	if err := s.feedService.OnUnfollow(ctx, followerID, followeeID); err != nil {
+		log.Printf("Error pruning timeline of %s: %v", followerID, err)
+	}
+
+	return nil
+}
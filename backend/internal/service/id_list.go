@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"social-media-app/internal/metrics"
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// idListFilledMember marks an ID list as filled from the database. It scores
+// +inf so it always ranks first and trimming never removes it.
+const idListFilledMember = "_filled"
+
+// pushIDScript adds an ID to a list and trims it to ARGV[3] IDs. A list
+// created by a push has no filled marker, so reads still fill it in full.
+var pushIDScript = redis.NewScript(`
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[3]) - 2)
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[4])
+end
+return 1
+`)
+
+// fillIDsScript merges IDs read from the database into a list and marks it
+// filled, unless the list was invalidated since the generation was read.
+// Merging rather than replacing keeps IDs pushed while the fill was loading.
+var fillIDsScript = redis.NewScript(`
+if (redis.call("GET", KEYS[2]) or "") ~= ARGV[1] then
+	return 0
+end
+redis.call("ZADD", KEYS[1], "+inf", ARGV[4])
+for i = 5, #ARGV, 2 do
+	redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[2]) - 2)
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+// IDEntry is an ID in a list, ordered by score
+type IDEntry struct {
+	ID    uuid.UUID
+	Score float64
+}
+
+// idScore orders list entries by creation time. Microseconds keep the order
+// of the database's (created_at, id) index and are exact in a float64.
+func idScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// cursor returns the page cursor at an entry's position, whether or not its
+// entity still loads
+func (e IDEntry) cursor() *model.Cursor {
+	return &model.Cursor{CreatedAt: time.UnixMicro(int64(e.Score)).UTC(), ID: e.ID}
+}
+
+// entryIDs returns the IDs of entries, in order
+func entryIDs(entries []IDEntry) []uuid.UUID {
+	ids := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+// IDList is a capped sorted set of the newest IDs of a collection (the feed,
+// a post's messages), highest score first. Writes push new IDs into it rather
+// than dropping it, so it stays warm under write-heavy load, and readers
+// hydrate the entities from their own caches.
+type IDList[K comparable] struct {
+	redis     *RedisService
+	namespace string
+	size      int
+	ttl       time.Duration
+	keyOf     func(K) string
+}
+
+// NewIDList returns lists of up to size IDs under namespace, refilled from the database after ttl
+func NewIDList[K comparable](redisService *RedisService, namespace string, size int, ttl time.Duration, keyOf func(K) string) *IDList[K] {
+	return &IDList[K]{
+		redis:     redisService,
+		namespace: namespace,
+		size:      size,
+		ttl:       ttl,
+		keyOf:     keyOf,
+	}
+}
+
+func (l *IDList[K]) key(k K) string {
+	return l.redis.cacheKey(l.namespace, l.keyOf(k))
+}
+
+// Get returns up to count of the newest entries of the list of k. If the list
+// is not filled, load is called for the newest size entries; concurrent fills
+// on an instance share one load.
+func (l *IDList[K]) Get(ctx context.Context, k K, count int, load func(ctx context.Context, size int) ([]IDEntry, error)) ([]IDEntry, error) {
+	key := l.key(k)
+
+	entries, filled, err := l.read(ctx, key, count)
+	if filled {
+		metrics.IncrementCacheRequests(l.namespace, cacheHit)
+		return entries, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		log.Printf("Error reading list %s: %v", key, err)
+	}
+	metrics.IncrementCacheRequests(l.namespace, cacheMiss)
+
+	// With Redis unavailable, go straight to the database
+	if err == nil {
+		loadCtx := context.WithoutCancel(ctx)
+		_, err, _ = l.redis.flight.do(ctx, "fill:"+key, func() ([]byte, error) {
+			return nil, l.fill(loadCtx, key, load)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if entries, filled, _ := l.read(ctx, key, count); filled {
+			return entries, nil
+		}
+	}
+
+	// The fill failed or lost a race with an invalidation
+	return load(ctx, count)
+}
+
+// read returns up to count entries of a list, newest first, and whether it is filled
+func (l *IDList[K]) read(ctx context.Context, key string, count int) ([]IDEntry, bool, error) {
+	members, err := l.redis.client.ZRevRangeWithScores(ctx, key, 0, int64(count)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(members) == 0 || members[0].Member != idListFilledMember {
+		return nil, false, nil
+	}
+
+	entries := make([]IDEntry, 0, len(members)-1)
+	for _, member := range members[1:] {
+		id, _ := member.Member.(string)
+		if id, err := uuid.Parse(id); err == nil {
+			entries = append(entries, IDEntry{ID: id, Score: member.Score})
+		}
+	}
+	return entries, true, nil
+}
+
+func (l *IDList[K]) fill(ctx context.Context, key string, load func(ctx context.Context, size int) ([]IDEntry, error)) error {
+	generation, err := l.redis.client.Get(ctx, cacheGenerationKey(key)).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Error reading list generation %s: %v", key, err)
+	}
+
+	entries, err := load(ctx, l.size)
+	if err != nil {
+		return err
+	}
+
+	args := make([]interface{}, 0, 4+2*len(entries))
+	args = append(args, generation, l.size, l.ttl.Milliseconds(), idListFilledMember)
+	for _, entry := range entries {
+		args = append(args, entry.Score, entry.ID.String())
+	}
+	keys := []string{key, cacheGenerationKey(key)}
+	if err := fillIDsScript.Run(ctx, l.redis.client, keys, args...).Err(); err != nil {
+		log.Printf("Error filling list %s: %v", key, err)
+	}
+	return nil
+}
+
+// Push adds a new ID to the list of k, dropping the oldest beyond the list's size
+func (l *IDList[K]) Push(ctx context.Context, k K, entry IDEntry) error {
+	ctx = context.WithoutCancel(ctx)
+	return pushIDScript.Run(ctx, l.redis.client, []string{l.key(k)},
+		entry.Score, entry.ID.String(), l.size, l.ttl.Milliseconds()).Err()
+}
+
+// Invalidate drops the list of k so the next read refills it, and stops fills
+// in progress from storing what they read before the change
+func (l *IDList[K]) Invalidate(ctx context.Context, k K) error {
+	ctx = context.WithoutCancel(ctx)
+	key := l.key(k)
+
+	pipe := l.redis.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.Incr(ctx, cacheGenerationKey(key))
+	pipe.Expire(ctx, cacheGenerationKey(key), cacheGenerationTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
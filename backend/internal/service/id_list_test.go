@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestIDList(t *testing.T, size int) (*IDList[string], func(string) string) {
+	t.Helper()
+	s, _ := newTestRedisService(t, "json")
+	list := NewIDList[string](s, "test", size, time.Minute, func(k string) string { return k })
+	return list, list.key
+}
+
+// entries returns n entries, newest first, scored from base down
+func entries(n int, base float64) []IDEntry {
+	out := make([]IDEntry, n)
+	for i := range out {
+		out[i] = IDEntry{ID: uuid.New(), Score: base - float64(i)}
+	}
+	return out
+}
+
+func ids(entries ...IDEntry) []uuid.UUID {
+	out := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		out[i] = entry.ID
+	}
+	return out
+}
+
+func assertIDs(t *testing.T, got, want []uuid.UUID) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d IDs %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ID %d is %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+// staticLoad returns a loader of the given entries that counts its calls
+func staticLoad(stored []IDEntry, calls *atomic.Int32) func(ctx context.Context, size int) ([]IDEntry, error) {
+	return func(ctx context.Context, size int) ([]IDEntry, error) {
+		calls.Add(1)
+		if size < len(stored) {
+			return stored[:size], nil
+		}
+		return stored, nil
+	}
+}
+
+func TestIDListPushBeforeFill(t *testing.T) {
+	list, _ := newTestIDList(t, 10)
+	ctx := context.Background()
+	stored := entries(3, 100)
+
+	// A push to a list never filled must not make it look filled
+	pushed := IDEntry{ID: uuid.New(), Score: 200}
+	if err := list.Push(ctx, "feed", pushed); err != nil {
+		t.Fatal(err)
+	}
+
+	var loads atomic.Int32
+	got, err := list.Get(ctx, "feed", 10, staticLoad(stored, &loads))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loads.Load() != 1 {
+		t.Fatalf("list with only a pushed ID was not filled (%d loads)", loads.Load())
+	}
+	// The fill merges with the push instead of replacing it
+	assertIDs(t, ids(got...), ids(append([]IDEntry{pushed}, stored...)...))
+
+	// Pushes to a filled list are read without loading
+	newer := IDEntry{ID: uuid.New(), Score: 300}
+	if err := list.Push(ctx, "feed", newer); err != nil {
+		t.Fatal(err)
+	}
+	got, err = list.Get(ctx, "feed", 2, staticLoad(stored, &loads))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loads.Load() != 1 {
+		t.Fatal("filled list was loaded again")
+	}
+	assertIDs(t, ids(got...), ids(newer, pushed))
+}
+
+func TestIDListFillRacingInvalidate(t *testing.T) {
+	list, key := newTestIDList(t, 10)
+	ctx := context.Background()
+	stale, fresh := entries(2, 100), entries(3, 200)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var loads atomic.Int32
+	result := make(chan []IDEntry, 1)
+	go func() {
+		got, err := list.Get(ctx, "feed", 10, func(ctx context.Context, size int) ([]IDEntry, error) {
+			if loads.Add(1) == 1 {
+				close(started)
+				<-release
+				return stale, nil
+			}
+			// The caller falls back to a direct read after losing the race
+			return fresh, nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		result <- got
+	}()
+
+	<-started
+	if err := list.Invalidate(ctx, "feed"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	assertIDs(t, ids(<-result...), ids(fresh...))
+	if n, _ := list.redis.client.Exists(ctx, key("feed")).Result(); n != 0 {
+		t.Fatal("fill that raced an invalidation was stored")
+	}
+
+	// The next read fills the list from scratch
+	var refills atomic.Int32
+	got, err := list.Get(ctx, "feed", 10, staticLoad(fresh, &refills))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, ids(got...), ids(fresh...))
+	if _, err := list.Get(ctx, "feed", 10, staticLoad(fresh, &refills)); err != nil {
+		t.Fatal(err)
+	}
+	if refills.Load() != 1 {
+		t.Fatalf("refilled %d times, want 1", refills.Load())
+	}
+}
+
+func TestIDListTrimsToSize(t *testing.T) {
+	list, key := newTestIDList(t, 3)
+	ctx := context.Background()
+
+	// A fill that returns more than size keeps only the newest
+	stored := entries(5, 100)
+	var loads atomic.Int32
+	got, err := list.Get(ctx, "feed", 10, func(ctx context.Context, size int) ([]IDEntry, error) {
+		loads.Add(1)
+		return stored, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, ids(got...), ids(stored[:3]...))
+
+	pushed := []IDEntry{{ID: uuid.New(), Score: 200}, {ID: uuid.New(), Score: 201}}
+	for _, entry := range pushed {
+		if err := list.Push(ctx, "feed", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// size IDs plus the filled marker, which trimming never removes
+	if n, _ := list.redis.client.ZCard(ctx, key("feed")).Result(); n != 4 {
+		t.Fatalf("list holds %d members, want 4", n)
+	}
+	got, err = list.Get(ctx, "feed", 10, staticLoad(stored, &loads))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loads.Load() != 1 {
+		t.Fatal("trimming dropped the filled marker")
+	}
+	assertIDs(t, ids(got...), ids(pushed[1], pushed[0], stored[0]))
+
+	if ttl, _ := list.redis.client.PTTL(ctx, key("feed")).Result(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("list TTL is %v", ttl)
+	}
+}
+
+// A cursor built from a list position matches the one built from the row
+func TestIDEntryCursor(t *testing.T) {
+	created := time.Date(2026, 3, 14, 15, 9, 26, 535897000, time.UTC)
+	entry := IDEntry{ID: uuid.New(), Score: idScore(created)}
+
+	cursor := entry.cursor()
+	if !cursor.CreatedAt.Equal(created) || cursor.ID != entry.ID {
+		t.Fatalf("got %v %s, want %v %s", cursor.CreatedAt, cursor.ID, created, entry.ID)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is the bounded in-process tier in front of Redis. Entries live
+// for at most ttl, and never past the expiry of the Redis entry they copy.
+// A nil *lruCache is a disabled cache: every lookup misses.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List // most recently used first
+	// version changes on every removal, so a fill that raced with an
+	// invalidation can be detected and skipped
+	version uint64
+}
+
+type lruItem struct {
+	key       string
+	entry     *cacheEntry
+	expiresAt time.Time
+}
+
+// newLRUCache returns a cache of up to size entries, or nil if size or ttl is not positive
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := element.Value.(*lruItem)
+	if time.Now().After(item.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return item.entry, true
+}
+
+// set stores an entry read while the cache was at the given version. It is
+// dropped if anything was removed since, as it may be what was removed.
+func (c *lruCache) set(key string, entry *cacheEntry, version uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		return
+	}
+
+	expiresAt := time.Now().Add(c.ttl)
+	if redisExpiry := time.UnixMilli(entry.ExpiresAt); redisExpiry.Before(expiresAt) {
+		expiresAt = redisExpiry
+	}
+
+	if element, ok := c.items[key]; ok {
+		element.Value = &lruItem{key: key, entry: entry, expiresAt: expiresAt}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem{key: key, entry: entry, expiresAt: expiresAt})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	if element, ok := c.items[key]; ok {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+// currentVersion returns the version to pass to set for data read from now on
+func (c *lruCache) currentVersion() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
@@ -1,64 +1,289 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"log"
 	"social-media-app/internal/metrics"
 	"social-media-app/internal/model"
 	"social-media-app/internal/repository"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// messageEditWindow is how long after sending a message its sender may edit it
+const messageEditWindow = 15 * time.Minute
+
+var (
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrEditWindowExpired = errors.New("message can no longer be edited")
+)
+
 type MessageService struct {
-	repo         *repository.MessageRepository
-	redisService *RedisService
+	repo                *repository.MessageRepository
+	postRepo            *repository.PostRepository
+	postCache           *Cache[uuid.UUID, *model.Post]
+	messageCache        *Cache[uuid.UUID, *model.Message]
+	messagesList        *IDList[uuid.UUID]
+	reactionService     *ReactionService
+	notificationService *NotificationService
+	entityService       *EntityService
 }
 
-func NewMessageService(repo *repository.MessageRepository, redisService *RedisService) *MessageService {
+func NewMessageService(repo *repository.MessageRepository, postRepo *repository.PostRepository, redisService *RedisService, reactionService *ReactionService, notificationService *NotificationService, entityService *EntityService) *MessageService {
 	return &MessageService{
-		repo:         repo,
-		redisService: redisService,
+		repo:                repo,
+		postRepo:            postRepo,
+		postCache:           newPostCache(redisService),
+		messageCache:        newMessageCache(redisService),
+		messagesList:        newPostMessagesList(redisService),
+		reactionService:     reactionService,
+		notificationService: notificationService,
+		entityService:       entityService,
 	}
 }
 
-func (s *MessageService) CreateMessage(postID, senderID uuid.UUID, req *model.CreateMessageRequest) (*model.Message, error) {
+func (s *MessageService) CreateMessage(ctx context.Context, postID, senderID uuid.UUID, req *model.CreateMessageRequest) (*model.Message, error) {
+	post, err := s.getLivePost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
 	message := &model.Message{
 		PostID:   postID,
 		SenderID: senderID,
 		Message:  req.Message,
+		Entities: s.entityService.Extract(req.Message),
 	}
 
-	err := s.repo.Create(message)
-	if err != nil {
+	if err := s.repo.Create(message); err != nil {
 		return nil, err
 	}
 
-	// Invalidate messages cache for this post
-	s.redisService.InvalidatePostMessages(postID.String())
+	// Write through to the post's cached messages, so they stay warm in a busy thread
+	if err := s.messagesList.Push(ctx, postID, messageEntry(message)); err != nil {
+		log.Printf("Error adding message %s to cached messages: %v", message.ID, err)
+	}
 
 	// Increment metrics
 	metrics.IncrementMessagesCreated()
 
+	s.entityService.RecordUsage(ctx, message.Entities)
+	s.notificationService.NotifyMention(ctx, message.Entities.MentionedUserIDs(), postID, senderID)
+	s.notificationService.NotifyComment(ctx, post, senderID)
+
+	return message, nil
+}
+
+// getLivePost returns the post a message belongs to, through the post cache.
+// Posts that are missing or deleted take no messages and serve no history.
+func (s *MessageService) getLivePost(ctx context.Context, postID uuid.UUID) (*model.Post, error) {
+	post, err := s.postCache.Get(ctx, postID, loadPost(s.postRepo, postID))
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
+// EditMessage changes the text of a message. Only the sender may edit, and only within the edit window.
+func (s *MessageService) EditMessage(ctx context.Context, postID, messageID, userID uuid.UUID, req *model.UpdateMessageRequest) (*model.Message, error) {
+	message, err := s.getPostMessage(postID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, ErrForbidden
+	}
+	if time.Since(message.CreatedAt) > messageEditWindow {
+		return nil, ErrEditWindowExpired
+	}
+
+	if err := s.repo.UpdateText(message, req.Message, s.entityService.Extract(req.Message)); err != nil {
+		return nil, err
+	}
+
+	// Invalidate the cached message; the post's cached list only holds its ID
+	s.messageCache.Invalidate(ctx, messageID)
+
+	return message, nil
+}
+
+// DeleteMessage removes a message. The sender and the owner of the post may delete it.
+func (s *MessageService) DeleteMessage(ctx context.Context, postID, messageID, userID uuid.UUID) error {
+	message, err := s.getPostMessage(postID, messageID)
+	if err != nil {
+		return err
+	}
+
+	if message.SenderID != userID {
+		post, err := s.postRepo.GetByID(postID)
+		if err != nil {
+			return err
+		}
+		if post == nil || post.UserID != userID {
+			return ErrForbidden
+		}
+	}
+
+	if err := s.repo.Delete(message); err != nil {
+		return err
+	}
+
+	// Invalidate the cached message, and refill the post's cached list rather
+	// than trimming it, so it still holds a full page afterwards
+	s.messageCache.Invalidate(ctx, messageID)
+	s.messagesList.Invalidate(ctx, postID)
+
+	return nil
+}
+
+func (s *MessageService) getPostMessage(postID, messageID uuid.UUID) (*model.Message, error) {
+	message, err := s.repo.GetByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.PostID != postID {
+		return nil, ErrMessageNotFound
+	}
 	return message, nil
 }
 
-func (s *MessageService) GetMessagesByPostID(postID uuid.UUID) ([]*model.Message, error) {
-	// Try cache first
-	var cachedMessages []*model.Message
-	err := s.redisService.GetCachedPostMessages(postID.String(), &cachedMessages)
-	if err == nil && len(cachedMessages) > 0 {
-		return cachedMessages, nil
+// GetMessagesPage returns a window of a post's messages with live reaction counts
+func (s *MessageService) GetMessagesPage(ctx context.Context, postID uuid.UUID, query *model.MessageQuery) (*model.MessagePage, error) {
+	page, err := s.getMessagesPage(ctx, postID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.reactionService.AttachToMessages(ctx, page.Messages)
+	return page, nil
+}
+
+// getMessagesPage returns a window of a post's messages. The latest page is
+// read from the post's cached list of message IDs and hydrated from the
+// message cache.
+func (s *MessageService) getMessagesPage(ctx context.Context, postID uuid.UUID, query *model.MessageQuery) (*model.MessagePage, error) {
+	if _, err := s.getLivePost(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	limit := model.ClampPageSize(query.Limit)
+
+	switch {
+	case query.Before != nil:
+		return s.loadOlder(postID, query.Before, limit)
+	case query.After != nil:
+		messages, err := s.repo.GetAfter(postID, query.After, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		return newerPage(messages, limit), nil
+	case query.Since != nil:
+		messages, err := s.repo.GetSince(postID, *query.Since, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		return newerPage(messages, limit), nil
 	}
 
-	// Cache miss, get from database
-	messages, err := s.repo.GetByPostID(postID)
+	page, stale, err := s.readLatestPage(ctx, postID, limit)
+	if err != nil || !stale {
+		return page, err
+	}
+
+	// The list holds messages that are gone, so it missed a deletion. Refill
+	// it and read again rather than serve a short page.
+	if err := s.messagesList.Invalidate(ctx, postID); err != nil {
+		log.Printf("Error invalidating cached messages of post %s: %v", postID, err)
+		return page, nil
+	}
+	page, _, err = s.readLatestPage(ctx, postID, limit)
+	return page, err
+}
+
+// readLatestPage reads the latest messages of a post from its cached list, and
+// reports whether the list held IDs of messages that no longer exist. The
+// cursor comes from the list, so such IDs never move it.
+func (s *MessageService) readLatestPage(ctx context.Context, postID uuid.UUID, limit int) (*model.MessagePage, bool, error) {
+	// Fetch one extra ID to know whether older messages exist
+	entries, err := s.messagesList.Get(ctx, postID, limit+1, func(ctx context.Context, size int) ([]IDEntry, error) {
+		messages, err := s.repo.GetBefore(postID, nil, size)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]IDEntry, len(messages))
+		for i, message := range messages {
+			entries[i] = messageEntry(message)
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	page := &model.MessagePage{}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		page.PrevCursor = entries[limit-1].cursor().Encode()
+	}
+
+	cached, err := s.messageCache.GetMany(ctx, entryIDs(entries), func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*model.Message, error) {
+		messages, err := s.repo.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[uuid.UUID]*model.Message, len(messages))
+		for _, message := range messages {
+			byID[message.ID] = message
+		}
+		return byID, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The list is newest first; pages are oldest first
+	page.Messages = make([]*model.Message, 0, len(cached))
+	for i := len(cached) - 1; i >= 0; i-- {
+		if cached[i] != nil {
+			page.Messages = append(page.Messages, cached[i])
+		}
+	}
+	return page, len(page.Messages) < len(entries), nil
+}
+
+func (s *MessageService) loadOlder(postID uuid.UUID, cursor *model.Cursor, limit int) (*model.MessagePage, error) {
+	// Fetch one extra row to know whether older messages exist
+	messages, err := s.repo.GetBefore(postID, cursor, limit+1)
 	if err != nil {
 		return nil, err
 	}
 
-	// Cache the result
-	if len(messages) > 0 {
-		s.redisService.CachePostMessages(postID.String(), messages)
+	page := &model.MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[1:]
+		page.PrevCursor = page.Messages[0].Cursor().Encode()
 	}
 
-	return messages, nil
+	return page, nil
+}
+
+// newerPage builds a page from limit+1 messages fetched oldest first
+func newerPage(messages []*model.Message, limit int) *model.MessagePage {
+	page := &model.MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = messages[limit-1].Cursor().Encode()
+	}
+
+	return page
+}
+
+// messageEntry places a message in its post's cached list
+func messageEntry(message *model.Message) IDEntry {
+	return IDEntry{ID: message.ID, Score: idScore(message.CreatedAt)}
 }
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// Notifier delivers events to users' live connections. The WebSocket hub
+// implements it; it is set after construction because the hub depends on
+// services that raise notifications.
+type Notifier interface {
+	SendToUsers(ctx context.Context, userIDs []uuid.UUID, eventType string, content interface{})
+}
+
+type NotificationService struct {
+	repo     *repository.NotificationRepository
+	notifier Notifier
+}
+
+func NewNotificationService(repo *repository.NotificationRepository) *NotificationService {
+	return &NotificationService{repo: repo}
+}
+
+// SetNotifier sets where new notifications are pushed. Must be called before serving requests.
+func (s *NotificationService) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
+}
+
+// NotifyComment tells a post's owner that someone commented on it
+func (s *NotificationService) NotifyComment(ctx context.Context, post *model.Post, commenterID uuid.UUID) {
+	s.notify(ctx, &model.Notification{
+		UserID:   post.UserID,
+		GroupKey: model.NotificationComment + ":" + post.ID.String(),
+		Type:     model.NotificationComment,
+		PostID:   &post.ID,
+		ActorID:  commenterID,
+	})
+}
+
+// NotifyFollow tells a user that someone followed them
+func (s *NotificationService) NotifyFollow(ctx context.Context, followeeID, followerID uuid.UUID) {
+	s.notify(ctx, &model.Notification{
+		UserID:   followeeID,
+		GroupKey: model.NotificationFollow,
+		Type:     model.NotificationFollow,
+		ActorID:  followerID,
+	})
+}
+
+// NotifyMention tells users they were mentioned in a post or one of its messages
+func (s *NotificationService) NotifyMention(ctx context.Context, userIDs []uuid.UUID, postID, actorID uuid.UUID) {
+	for _, userID := range userIDs {
+		s.notify(ctx, &model.Notification{
+			UserID:   userID,
+			GroupKey: model.NotificationMention + ":" + postID.String(),
+			Type:     model.NotificationMention,
+			PostID:   &postID,
+			ActorID:  actorID,
+		})
+	}
+}
+
+// notify records a notification and pushes it to the recipient. Notifications
+// are best effort: failures are logged and never fail the triggering action.
+func (s *NotificationService) notify(ctx context.Context, notification *model.Notification) {
+	if notification.UserID == notification.ActorID {
+		return
+	}
+
+	stored, err := s.repo.Record(notification)
+	if err != nil {
+		log.Printf("Error recording %s notification for %s: %v", notification.Type, notification.UserID, err)
+		return
+	}
+
+	if s.notifier == nil {
+		return
+	}
+	unread, err := s.repo.CountUnread(notification.UserID)
+	if err != nil {
+		log.Printf("Error counting notifications of %s: %v", notification.UserID, err)
+		return
+	}
+	s.notifier.SendToUsers(ctx, []uuid.UUID{notification.UserID}, "notification", &model.NotificationEvent{
+		Notification: stored,
+		UnreadCount:  unread,
+	})
+}
+
+// ListNotifications returns a page of the user's notifications, most recently active first
+func (s *NotificationService) ListNotifications(userID uuid.UUID, cursor *model.Cursor, limit int) (*model.NotificationPage, error) {
+	limit = model.ClampPageSize(limit)
+
+	notifications, err := s.repo.GetPageForUser(userID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.NotificationPage{Notifications: notifications}
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		page.NextCursor = page.Notifications[limit-1].Cursor().Encode()
+	}
+	return page, nil
+}
+
+// CountUnread returns the user's unread notifications
+func (s *NotificationService) CountUnread(userID uuid.UUID) (int64, error) {
+	return s.repo.CountUnread(userID)
+}
+
+// MarkRead marks one of the user's notifications read. Marking an already
+// read notification again is not an error.
+func (s *NotificationService) MarkRead(notificationID, userID uuid.UUID) error {
+	marked, err := s.repo.MarkRead(notificationID, userID)
+	if err != nil || marked {
+		return err
+	}
+
+	notification, err := s.repo.GetByID(notificationID)
+	if err != nil {
+		return err
+	}
+	if notification == nil || notification.UserID != userID {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks all of the user's notifications read and returns how many were unread
+func (s *NotificationService) MarkAllRead(userID uuid.UUID) (int64, error) {
+	return s.repo.MarkAllRead(userID)
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"log"
 	"social-media-app/internal/metrics"
 	"social-media-app/internal/model"
 	"social-media-app/internal/repository"
@@ -8,23 +11,43 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	ErrPostNotFound = errors.New("post not found")
+	ErrForbidden    = errors.New("you are not allowed to modify this resource")
+)
+
 type PostService struct {
-	repo         *repository.PostRepository
-	redisService *RedisService
+	repo                *repository.PostRepository
+	postCache           *Cache[uuid.UUID, *model.Post]
+	feedList            *IDList[string]
+	messagesList        *IDList[uuid.UUID]
+	feedService         *FeedService
+	uploadService       *UploadService
+	reactionService     *ReactionService
+	entityService       *EntityService
+	notificationService *NotificationService
 }
 
-func NewPostService(repo *repository.PostRepository, redisService *RedisService) *PostService {
+func NewPostService(repo *repository.PostRepository, redisService *RedisService, feedService *FeedService, uploadService *UploadService, reactionService *ReactionService, entityService *EntityService, notificationService *NotificationService) *PostService {
 	return &PostService{
-		repo:         repo,
-		redisService: redisService,
+		repo:                repo,
+		postCache:           newPostCache(redisService),
+		feedList:            newPostsFeedList(redisService),
+		messagesList:        newPostMessagesList(redisService),
+		feedService:         feedService,
+		uploadService:       uploadService,
+		reactionService:     reactionService,
+		entityService:       entityService,
+		notificationService: notificationService,
 	}
 }
 
-func (s *PostService) CreatePost(userID uuid.UUID, req *model.CreatePostRequest) (*model.Post, error) {
+func (s *PostService) CreatePost(ctx context.Context, userID uuid.UUID, req *model.CreatePostRequest) (*model.Post, error) {
 	post := &model.Post{
 		UserID:   userID,
 		ImageURL: req.ImageURL,
 		Caption:  req.Caption,
+		Entities: s.entityService.Extract(req.Caption),
 	}
 
 	err := s.repo.Create(post)
@@ -32,8 +55,16 @@ func (s *PostService) CreatePost(userID uuid.UUID, req *model.CreatePostRequest)
 		return nil, err
 	}
 
-	// Invalidate posts feed cache
-	s.redisService.InvalidatePostsFeed()
+	s.entityService.RecordUsage(ctx, post.Entities)
+	s.notificationService.NotifyMention(ctx, post.Entities.MentionedUserIDs(), post.ID, userID)
+
+	// Write through to the cached feed, so it stays warm while posts keep coming
+	if err := s.feedList.Push(ctx, postsFeedKey, postEntry(post)); err != nil {
+		log.Printf("Error adding post %s to cached feed: %v", post.ID, err)
+	}
+
+	// Push into followers' home timelines
+	go s.feedService.FanOutPost(context.WithoutCancel(ctx), post)
 
 	// Increment metrics
 	metrics.IncrementPostsCreated()
@@ -41,50 +72,226 @@ func (s *PostService) CreatePost(userID uuid.UUID, req *model.CreatePostRequest)
 	return post, nil
 }
 
-func (s *PostService) GetPost(id uuid.UUID) (*model.Post, error) {
-	// Try cache first
-	var cachedPost model.Post
-	err := s.redisService.GetCachedPost(id.String(), &cachedPost)
-	if err == nil {
-		return &cachedPost, nil
+// UpdatePost changes the caption of a post owned by userID
+func (s *PostService) UpdatePost(ctx context.Context, id, userID uuid.UUID, req *model.UpdatePostRequest) (*model.Post, error) {
+	post, err := s.getOwnedPost(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateCaption(post, req.Caption, s.entityService.Extract(req.Caption)); err != nil {
+		return nil, err
+	}
+
+	// Invalidate the cached post; the cached feed only holds its ID
+	s.postCache.Invalidate(ctx, id)
+
+	return post, nil
+}
+
+// DeletePost soft-deletes a post owned by userID and removes its image
+func (s *PostService) DeletePost(ctx context.Context, id, userID uuid.UUID) error {
+	post, err := s.getOwnedPost(id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(post); err != nil {
+		return err
 	}
 
-	// Cache miss, get from database
+	// Invalidate every cache holding the post or its messages. The feed is
+	// refilled rather than trimmed, so it still holds a full page afterwards.
+	s.postCache.Invalidate(ctx, id)
+	s.feedList.Invalidate(ctx, postsFeedKey)
+	s.messagesList.Invalidate(ctx, id)
+
+	// Take it out of home timelines, or their pages come up short
+	go s.feedService.RemovePost(context.WithoutCancel(ctx), post)
+
+	// Only remove the image once no other post references it
+	count, err := s.repo.CountByImageURL(post.ImageURL)
+	if err == nil && count == 0 {
+		err = s.uploadService.DeleteImage(post.ImageURL)
+	}
+	if err != nil {
+		log.Printf("Error removing image of post %s: %v", id, err)
+	}
+
+	return nil
+}
+
+func (s *PostService) getOwnedPost(id, userID uuid.UUID) (*model.Post, error) {
 	post, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+	if post.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return post, nil
+}
 
-	if post != nil {
-		// Cache the result
-		s.redisService.CachePost(id.String(), post)
+func (s *PostService) GetPost(ctx context.Context, id uuid.UUID) (*model.Post, error) {
+	post, err := s.postCache.Get(ctx, id, loadPost(s.repo, id))
+	if err != nil || post == nil {
+		return nil, err
 	}
 
+	// Add the live counters
+	s.reactionService.AttachToPosts(ctx, []*model.Post{post})
 	return post, nil
 }
 
-func (s *PostService) GetAllPosts() ([]*model.Post, error) {
-	// Try cache first
-	var cachedPosts []*model.Post
-	err := s.redisService.GetCachedPostsFeed(&cachedPosts)
-	if err == nil && len(cachedPosts) > 0 {
-		return cachedPosts, nil
+// loadPost loads a post for the post cache. Cache-aside; a missing or deleted
+// post is cached briefly as null.
+func loadPost(repo *repository.PostRepository, id uuid.UUID) func(ctx context.Context) (*model.Post, bool, error) {
+	return func(ctx context.Context) (*model.Post, bool, error) {
+		post, err := repo.GetByID(id)
+		return post, post == nil, err
+	}
+}
+
+// GetPostsPage returns a page of the feed, newest first, with live reaction counts
+func (s *PostService) GetPostsPage(ctx context.Context, cursor *model.Cursor, limit int) (*model.PostPage, error) {
+	page, err := s.getPostsPage(ctx, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.reactionService.AttachToPosts(ctx, page.Posts)
+	return page, nil
+}
+
+// getPostsPage returns a page of the feed. The first page is read from the
+// cached list of the newest post IDs and hydrated from the post cache.
+func (s *PostService) getPostsPage(ctx context.Context, cursor *model.Cursor, limit int) (*model.PostPage, error) {
+	limit = model.ClampPageSize(limit)
+
+	// Deeper pages go straight to the (created_at, id) index
+	if cursor != nil {
+		return s.loadPage(cursor, limit)
 	}
 
-	// Cache miss, get from database
-	posts, err := s.repo.GetAll()
+	page, stale, err := s.readFeedPage(ctx, limit)
+	if err != nil || !stale {
+		return page, err
+	}
+
+	// The list holds posts that are gone, so it missed a deletion. Refill it
+	// and read again rather than serve a short page.
+	if err := s.feedList.Invalidate(ctx, postsFeedKey); err != nil {
+		log.Printf("Error invalidating cached feed: %v", err)
+		return page, nil
+	}
+	page, _, err = s.readFeedPage(ctx, limit)
+	return page, err
+}
+
+// readFeedPage reads the first page of the feed from its cached list, and
+// reports whether the list held IDs of posts that no longer exist. The cursor
+// comes from the list, so such IDs never move it.
+func (s *PostService) readFeedPage(ctx context.Context, limit int) (*model.PostPage, bool, error) {
+	// Fetch one extra ID to know whether another page exists
+	entries, err := s.feedList.Get(ctx, postsFeedKey, limit+1, func(ctx context.Context, size int) ([]IDEntry, error) {
+		posts, err := s.repo.GetPage(nil, size)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]IDEntry, len(posts))
+		for i, post := range posts {
+			entries[i] = postEntry(post)
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	page := &model.PostPage{}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		page.NextCursor = entries[limit-1].cursor().Encode()
+	}
+
+	page.Posts, err = s.getPostsByIDs(ctx, entryIDs(entries))
+	if err != nil {
+		return nil, false, err
+	}
+	return page, len(page.Posts) < len(entries), nil
+}
+
+func (s *PostService) loadPage(cursor *model.Cursor, limit int) (*model.PostPage, error) {
+	// Fetch one extra row to know whether another page exists
+	posts, err := s.repo.GetPage(cursor, limit+1)
 	if err != nil {
 		return nil, err
 	}
 
-	// Cache the result
-	if len(posts) > 0 {
-		s.redisService.CachePostsFeed(posts)
+	page := &model.PostPage{Posts: posts}
+	if len(posts) > limit {
+		page.Posts = posts[:limit]
+		page.NextCursor = posts[limit-1].Cursor().Encode()
 	}
 
+	return page, nil
+}
+
+// getPostsByIDs returns the posts with the given IDs in order, through the
+// post cache, skipping posts that no longer exist
+func (s *PostService) getPostsByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Post, error) {
+	cached, err := s.postCache.GetMany(ctx, ids, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*model.Post, error) {
+		posts, err := s.repo.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[uuid.UUID]*model.Post, len(posts))
+		for _, post := range posts {
+			byID[post.ID] = post
+		}
+		return byID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*model.Post, 0, len(cached))
+	for _, post := range cached {
+		if post != nil {
+			posts = append(posts, post)
+		}
+	}
 	return posts, nil
 }
 
+// postEntry places a post in the cached feed
+func postEntry(post *model.Post) IDEntry {
+	return IDEntry{ID: post.ID, Score: idScore(post.CreatedAt)}
+}
+
+// GetTagPostsPage returns a page of the posts tagged with a hashtag, newest
+// first, with live reaction counts
+func (s *PostService) GetTagPostsPage(ctx context.Context, tag string, cursor *model.Cursor, limit int) (*model.PostPage, error) {
+	limit = model.ClampPageSize(limit)
+
+	posts, err := s.repo.GetPageByTag(tag, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.PostPage{Posts: posts}
+	if len(posts) > limit {
+		page.Posts = posts[:limit]
+		page.NextCursor = posts[limit-1].Cursor().Encode()
+	}
+
+	s.reactionService.AttachToPosts(ctx, page.Posts)
+	return page, nil
+}
+
 func (s *PostService) GetUserPosts(userID uuid.UUID) ([]*model.Post, error) {
 	return s.repo.GetByUserID(userID)
 }
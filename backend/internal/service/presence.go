@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type PresenceService struct {
+	redisService *RedisService
+	postRepo     *repository.PostRepository
+	userRepo     *repository.UserRepository
+}
+
+func NewPresenceService(redisService *RedisService, postRepo *repository.PostRepository, userRepo *repository.UserRepository) *PresenceService {
+	return &PresenceService{
+		redisService: redisService,
+		postRepo:     postRepo,
+		userRepo:     userRepo,
+	}
+}
+
+// GetPostPresence returns who is currently connected to a post's discussion.
+// Presence is written by the WebSocket hubs of every instance.
+func (s *PresenceService) GetPostPresence(ctx context.Context, postID uuid.UUID) (*model.PresenceSnapshot, error) {
+	post, err := s.postRepo.GetByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+
+	userIDs, err := s.redisService.GetPresentUserIDs(ctx, postID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if id, err := uuid.Parse(userID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	users, err := s.userRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &model.PresenceSnapshot{
+		PostID: postID,
+		Users:  make([]model.PresenceUser, 0, len(users)),
+	}
+	for _, user := range users {
+		snapshot.Users = append(snapshot.Users, model.PresenceUser{ID: user.ID, Username: user.Username})
+	}
+	sort.Slice(snapshot.Users, func(i, j int) bool {
+		return snapshot.Users[i].Username < snapshot.Users[j].Username
+	})
+	snapshot.Count = len(snapshot.Users)
+
+	return snapshot, nil
+}
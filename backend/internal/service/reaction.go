@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// reactionBatchSize and reactionFlushInterval bound how long a reaction waits before reaching Postgres
+	reactionBatchSize     = 200
+	reactionFlushInterval = time.Second
+)
+
+var ErrInvalidReaction = errors.New("invalid reaction kind")
+
+type reactionWrite struct {
+	reaction *model.Reaction
+	on       bool
+}
+
+// ReactionService keeps hot reaction counters in Redis and writes the
+// underlying reactions to Postgres asynchronously in batches.
+type ReactionService struct {
+	repo         *repository.ReactionRepository
+	postRepo     *repository.PostRepository
+	messageRepo  *repository.MessageRepository
+	redisService *RedisService
+	writes       chan reactionWrite
+}
+
+func NewReactionService(repo *repository.ReactionRepository, postRepo *repository.PostRepository, messageRepo *repository.MessageRepository, redisService *RedisService) *ReactionService {
+	return &ReactionService{
+		repo:         repo,
+		postRepo:     postRepo,
+		messageRepo:  messageRepo,
+		redisService: redisService,
+		writes:       make(chan reactionWrite, 10000),
+	}
+}
+
+// SetPostReaction turns a user's reaction on a post on or off
+func (s *ReactionService) SetPostReaction(ctx context.Context, postID, userID uuid.UUID, kind string, on bool) (*model.ReactionUpdate, error) {
+	post, err := s.postRepo.GetByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+
+	return s.setReaction(ctx, model.ReactionTargetPost, postID, postID, userID, kind, on)
+}
+
+// SetMessageReaction turns a user's reaction on a message on or off
+func (s *ReactionService) SetMessageReaction(ctx context.Context, postID, messageID, userID uuid.UUID, kind string, on bool) (*model.ReactionUpdate, error) {
+	message, err := s.messageRepo.GetByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.PostID != postID {
+		return nil, ErrMessageNotFound
+	}
+
+	return s.setReaction(ctx, model.ReactionTargetMessage, messageID, postID, userID, kind, on)
+}
+
+func (s *ReactionService) setReaction(ctx context.Context, targetType string, targetID, postID, userID uuid.UUID, kind string, on bool) (*model.ReactionUpdate, error) {
+	if !model.ReactionKinds[kind] {
+		return nil, ErrInvalidReaction
+	}
+
+	if err := s.ensureLoaded(ctx, targetType, targetID); err != nil {
+		return nil, err
+	}
+
+	changed, count, err := s.redisService.SetReaction(ctx, targetType, targetID.String(), kind, userID.String(), on)
+	if errors.Is(err, ErrReactionsNotLoaded) {
+		// Expired since ensureLoaded read them
+		if err := s.load(ctx, targetType, []uuid.UUID{targetID}); err != nil {
+			return nil, err
+		}
+		changed, count, err = s.redisService.SetReaction(ctx, targetType, targetID.String(), kind, userID.String(), on)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		s.writes <- reactionWrite{
+			reaction: &model.Reaction{
+				TargetType: targetType,
+				TargetID:   targetID,
+				UserID:     userID,
+				Kind:       kind,
+			},
+			on: on,
+		}
+	}
+
+	return &model.ReactionUpdate{
+		TargetType: targetType,
+		TargetID:   targetID,
+		PostID:     postID,
+		Kind:       kind,
+		Count:      count,
+		Changed:    changed,
+	}, nil
+}
+
+// ensureLoaded seeds Redis from Postgres the first time a target is touched
+func (s *ReactionService) ensureLoaded(ctx context.Context, targetType string, targetID uuid.UUID) error {
+	counts, err := s.redisService.GetReactionCounts(ctx, targetType, []string{targetID.String()})
+	if err != nil {
+		return err
+	}
+	if _, ok := counts[targetID.String()]; ok {
+		return nil
+	}
+
+	return s.load(ctx, targetType, []uuid.UUID{targetID})
+}
+
+// load seeds Redis with the reactions of several targets, read in one query
+func (s *ReactionService) load(ctx context.Context, targetType string, targetIDs []uuid.UUID) error {
+	reactions, err := s.repo.GetByTargets(targetType, targetIDs)
+	if err != nil {
+		return err
+	}
+
+	// Every kind is present so stale user sets are cleared
+	users := make(map[uuid.UUID]map[string][]string, len(targetIDs))
+	for _, id := range targetIDs {
+		users[id] = make(map[string][]string, len(model.ReactionKinds))
+		for kind := range model.ReactionKinds {
+			users[id][kind] = nil
+		}
+	}
+	for _, reaction := range reactions {
+		users[reaction.TargetID][reaction.Kind] = append(users[reaction.TargetID][reaction.Kind], reaction.UserID.String())
+	}
+
+	for _, id := range targetIDs {
+		if err := s.redisService.LoadReactions(ctx, targetType, id.String(), users[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttachToPosts fills in the reaction counts of each post
+func (s *ReactionService) AttachToPosts(ctx context.Context, posts []*model.Post) {
+	ids := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	counts := s.getCounts(ctx, model.ReactionTargetPost, ids)
+	for _, post := range posts {
+		post.Reactions = counts[post.ID.String()]
+	}
+}
+
+// AttachToMessages fills in the reaction counts of each message
+func (s *ReactionService) AttachToMessages(ctx context.Context, messages []*model.Message) {
+	ids := make([]uuid.UUID, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	counts := s.getCounts(ctx, model.ReactionTargetMessage, ids)
+	for _, message := range messages {
+		message.Reactions = counts[message.ID.String()]
+	}
+}
+
+func (s *ReactionService) getCounts(ctx context.Context, targetType string, ids []uuid.UUID) map[string]map[string]int64 {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+
+	counts, err := s.redisService.GetReactionCounts(ctx, targetType, keys)
+	if err != nil {
+		// Counts are decoration; serve the entities without them
+		log.Printf("Error loading reaction counts: %v", err)
+		return nil
+	}
+
+	// Load targets Redis has not seen yet, then read them again
+	var missing []uuid.UUID
+	var loadedIDs []string
+	for _, id := range ids {
+		if _, ok := counts[id.String()]; !ok {
+			missing = append(missing, id)
+			loadedIDs = append(loadedIDs, id.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		if err := s.load(ctx, targetType, missing); err != nil {
+			log.Printf("Error loading reactions of %d %ss: %v", len(missing), targetType, err)
+			return counts
+		}
+
+		loaded, err := s.redisService.GetReactionCounts(ctx, targetType, loadedIDs)
+		if err == nil {
+			for id, targetCounts := range loaded {
+				counts[id] = targetCounts
+			}
+		}
+	}
+
+	return counts
+}
+
+// Run persists queued reaction changes in batches. It blocks forever.
+func (s *ReactionService) Run() {
+	ticker := time.NewTicker(reactionFlushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[model.Reaction]bool)
+	for {
+		select {
+		case write := <-s.writes:
+			// Only the latest state of each reaction needs to be written
+			pending[*write.reaction] = write.on
+			if len(pending) < reactionBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+		}
+
+		s.flush(pending)
+		pending = make(map[model.Reaction]bool)
+	}
+}
+
+func (s *ReactionService) flush(pending map[model.Reaction]bool) {
+	var added, removed []*model.Reaction
+	for reaction, on := range pending {
+		reaction := reaction
+		if on {
+			added = append(added, &reaction)
+		} else {
+			removed = append(removed, &reaction)
+		}
+	}
+
+	if err := s.repo.ApplyBatch(added, removed); err != nil {
+		log.Printf("Error persisting %d reactions: %v", len(pending), err)
+	}
+}
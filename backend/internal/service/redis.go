@@ -3,24 +3,38 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"social-media-app/internal/config"
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisService struct {
 	client *redis.Client
+	cfg    config.CacheConfig
+	flight flightGroup
+	l1     *lruCache
+	codec  Codec
 }
 
-func NewRedisService(client *redis.Client) *RedisService {
-	return &RedisService{client: client}
+func NewRedisService(client *redis.Client, cfg config.CacheConfig) *RedisService {
+	return &RedisService{
+		client: client,
+		cfg:    cfg,
+		l1:     newLRUCache(cfg.L1Size, cfg.L1TTL),
+		codec:  NewCodec(cfg.Codec),
+	}
 }
 
 // Cache operations
-func (s *RedisService) Set(key string, value interface{}, expiration time.Duration) error {
-	ctx := context.Background()
-
+func (s *RedisService) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
 		return err
@@ -29,10 +43,13 @@ func (s *RedisService) Set(key string, value interface{}, expiration time.Durati
 	return s.client.Set(ctx, key, jsonValue, expiration).Err()
 }
 
-func (s *RedisService) Get(key string, dest interface{}) error {
-	ctx := context.Background()
-
+// Get reads a JSON value. It returns ErrCacheMiss if the key is not set, so an
+// empty cached value (null, [] or {}) is never mistaken for a miss.
+func (s *RedisService) Get(ctx context.Context, key string, dest interface{}) error {
 	val, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return ErrCacheMiss
+	}
 	if err != nil {
 		return err
 	}
@@ -40,15 +57,12 @@ func (s *RedisService) Get(key string, dest interface{}) error {
 	return json.Unmarshal([]byte(val), dest)
 }
 
-func (s *RedisService) Delete(key string) error {
-	ctx := context.Background()
+func (s *RedisService) Delete(ctx context.Context, key string) error {
 	return s.client.Del(ctx, key).Err()
 }
 
 // Pub/Sub operations
-func (s *RedisService) Publish(channel string, message interface{}) error {
-	ctx := context.Background()
-
+func (s *RedisService) Publish(ctx context.Context, channel string, message interface{}) error {
 	jsonMessage, err := json.Marshal(message)
 	if err != nil {
 		return err
@@ -57,84 +71,544 @@ func (s *RedisService) Publish(channel string, message interface{}) error {
 	return s.client.Publish(ctx, channel, jsonMessage).Err()
 }
 
-func (s *RedisService) Subscribe(channel string) *redis.PubSub {
-	ctx := context.Background()
+func (s *RedisService) Subscribe(ctx context.Context, channel string) *redis.PubSub {
 	return s.client.Subscribe(ctx, channel)
 }
 
-// Helper methods for common cache keys
-func (s *RedisService) CachePost(postID string, post interface{}) error {
-	key := fmt.Sprintf("post:%s", postID)
-	return s.Set(key, post, 10*time.Minute)
+// Typed caches of the entities served through fetch, and the lists of
+// their newest IDs. Lists hold one ID more than the largest page, to tell
+// whether another page exists.
+const postsFeedKey = "feed"
+
+func newPostCache(s *RedisService) *Cache[uuid.UUID, *model.Post] {
+	return NewCache[uuid.UUID, *model.Post](s, "post", s.cfg.PostTTL, uuid.UUID.String)
 }
 
-func (s *RedisService) GetCachedPost(postID string, dest interface{}) error {
-	key := fmt.Sprintf("post:%s", postID)
-	return s.Get(key, dest)
+func newMessageCache(s *RedisService) *Cache[uuid.UUID, *model.Message] {
+	return NewCache[uuid.UUID, *model.Message](s, "message", s.cfg.MessagesTTL, uuid.UUID.String)
 }
 
-func (s *RedisService) InvalidatePostCache(postID string) error {
-	key := fmt.Sprintf("post:%s", postID)
-	return s.Delete(key)
+// newPostsFeedList lists the newest posts under postsFeedKey
+func newPostsFeedList(s *RedisService) *IDList[string] {
+	return NewIDList[string](s, "posts", model.MaxPageSize+1, s.cfg.FeedTTL, func(key string) string { return key })
 }
 
-// Cache posts feed
-func (s *RedisService) CachePostsFeed(posts interface{}) error {
-	return s.Set("posts:feed", posts, 5*time.Minute)
+// newPostMessagesList lists the newest messages of each post
+func newPostMessagesList(s *RedisService) *IDList[uuid.UUID] {
+	return NewIDList[uuid.UUID](s, "post_messages", model.MaxPageSize+1, s.cfg.MessagesTTL, uuid.UUID.String)
 }
 
-func (s *RedisService) GetCachedPostsFeed(dest interface{}) error {
-	return s.Get("posts:feed", dest)
+// Home timelines: sorted sets of post IDs scored by creation time (unix millis)
+const timelineTTL = 7 * 24 * time.Hour
+
+// addToTimelineScript only adds to timelines that already exist, so a missing
+// timeline is rebuilt in full on the next read instead of holding a single post
+var addToTimelineScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[3]) - 1)
+return 1
+`)
+
+func timelineKey(userID string) string {
+	return fmt.Sprintf("timeline:%s", userID)
 }
 
-func (s *RedisService) InvalidatePostsFeed() error {
-	return s.Delete("posts:feed")
+// AddToTimelines pushes a post into the existing timelines of the given users
+func (s *RedisService) AddToTimelines(ctx context.Context, userIDs []string, postID string, score float64, maxSize int) error {
+	pipe := s.client.Pipeline()
+	for _, userID := range userIDs {
+		addToTimelineScript.Eval(ctx, pipe, []string{timelineKey(userID)}, score, postID, maxSize)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// Cache user profile
-func (s *RedisService) CacheUserProfile(userID string, user interface{}) error {
-	key := fmt.Sprintf("user:%s", userID)
-	return s.Set(key, user, 30*time.Minute)
+// FillTimeline adds entries to a user's timeline, creating it if needed
+func (s *RedisService) FillTimeline(ctx context.Context, userID string, entries []redis.Z, maxSize int) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	key := timelineKey(userID)
+
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, key, entries...)
+	pipe.ZRemRangeByRank(ctx, key, 0, int64(-maxSize-1))
+	pipe.Expire(ctx, key, timelineTTL)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (s *RedisService) GetCachedUserProfile(userID string, dest interface{}) error {
-	key := fmt.Sprintf("user:%s", userID)
-	return s.Get(key, dest)
+// GetTimeline returns up to count post IDs with a score of at most maxScore, newest first
+func (s *RedisService) GetTimeline(ctx context.Context, userID string, maxScore string, count int64) ([]string, error) {
+	key := timelineKey(userID)
+
+	pipe := s.client.Pipeline()
+	ids := pipe.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{Max: maxScore, Min: "-inf", Count: count})
+	pipe.Expire(ctx, key, timelineTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return ids.Val(), nil
 }
 
-func (s *RedisService) InvalidateUserProfile(userID string) error {
-	key := fmt.Sprintf("user:%s", userID)
-	return s.Delete(key)
+func (s *RedisService) TimelineExists(ctx context.Context, userID string) (bool, error) {
+	n, err := s.client.Exists(ctx, timelineKey(userID)).Result()
+	return n > 0, err
 }
 
-// Cache post messages
-func (s *RedisService) CachePostMessages(postID string, messages interface{}) error {
-	key := fmt.Sprintf("messages:%s", postID)
-	return s.Set(key, messages, 2*time.Minute)
+func (s *RedisService) RemoveFromTimeline(ctx context.Context, userID string, postIDs []string) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = id
+	}
+	return s.client.ZRem(ctx, timelineKey(userID), members...).Err()
 }
 
-func (s *RedisService) GetCachedPostMessages(postID string, dest interface{}) error {
-	key := fmt.Sprintf("messages:%s", postID)
-	return s.Get(key, dest)
+// RemoveFromTimelines takes a post out of the timelines of the given users
+func (s *RedisService) RemoveFromTimelines(ctx context.Context, userIDs []string, postID string) error {
+	pipe := s.client.Pipeline()
+	for _, userID := range userIDs {
+		pipe.ZRem(ctx, timelineKey(userID), postID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (s *RedisService) InvalidatePostMessages(postID string) error {
-	key := fmt.Sprintf("messages:%s", postID)
-	return s.Delete(key)
+// Reactions: a hash of kind -> count per post or message, plus a set of user IDs per kind.
+// The hash always holds reactionsLoadedField once it has been loaded from the database.
+// All of a target's keys share reactionsTTL, refreshed whenever they are read
+// or written, so reactions on old content age out of Redis.
+const (
+	reactionsLoadedField = "_loaded"
+	reactionsTTL         = 24 * time.Hour
+)
+
+// ErrReactionsNotLoaded is returned when a target's reactions expired from
+// Redis before they could be changed; they must be loaded again
+var ErrReactionsNotLoaded = errors.New("reactions not loaded")
+
+// setReactionScript adds or removes a user's reaction and adjusts the counter
+// only when the set actually changed, so repeated requests are idempotent.
+// It refuses to touch a target whose counters are gone, as a counter created
+// here would not hold the reactions already in the database.
+var setReactionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return {-1, 0}
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+local changed
+if ARGV[3] == "1" then
+	changed = redis.call("SADD", KEYS[2], ARGV[1])
+else
+	changed = redis.call("SREM", KEYS[2], ARGV[1])
+end
+if changed == 1 then
+	local delta = 1
+	if ARGV[3] ~= "1" then
+		delta = -1
+	end
+	local count = redis.call("HINCRBY", KEYS[1], ARGV[2], delta)
+	redis.call("PEXPIRE", KEYS[2], ARGV[4])
+	return {1, count}
+end
+return {0, tonumber(redis.call("HGET", KEYS[1], ARGV[2]) or "0")}
+`)
+
+func reactionsKey(targetType, targetID string) string {
+	return fmt.Sprintf("reactions:%s:%s", targetType, targetID)
+}
+
+func reactionUsersKey(targetType, targetID, kind string) string {
+	return fmt.Sprintf("reactions:%s:%s:%s", targetType, targetID, kind)
+}
+
+// SetReaction turns a user's reaction on or off, returning whether it changed and the new count
+func (s *RedisService) SetReaction(ctx context.Context, targetType, targetID, kind, userID string, on bool) (bool, int64, error) {
+	flag := "0"
+	if on {
+		flag = "1"
+	}
+
+	keys := []string{reactionsKey(targetType, targetID), reactionUsersKey(targetType, targetID, kind)}
+	result, err := setReactionScript.Run(ctx, s.client, keys, userID, kind, flag, reactionsTTL.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if result[0] == -1 {
+		return false, 0, ErrReactionsNotLoaded
+	}
+
+	return result[0] == 1, result[1], nil
+}
+
+// GetReactionCounts returns the counters of each target, keeping them in
+// Redis for another reactionsTTL. Targets that have not been loaded into
+// Redis yet are absent from the result.
+func (s *RedisService) GetReactionCounts(ctx context.Context, targetType string, targetIDs []string) (map[string]map[string]int64, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(targetIDs))
+	for i, id := range targetIDs {
+		cmds[i] = pipe.HGetAll(ctx, reactionsKey(targetType, id))
+		pipe.Expire(ctx, reactionsKey(targetType, id), reactionsTTL)
+		for kind := range model.ReactionKinds {
+			pipe.Expire(ctx, reactionUsersKey(targetType, id, kind), reactionsTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]map[string]int64, len(targetIDs))
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			continue
+		}
+
+		targetCounts := make(map[string]int64)
+		for kind, value := range fields {
+			if kind == reactionsLoadedField {
+				continue
+			}
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+				targetCounts[kind] = n
+			}
+		}
+		counts[targetIDs[i]] = targetCounts
+	}
+
+	return counts, nil
+}
+
+// LoadReactions seeds a target's counters and user sets (kind -> user IDs) unless
+// they are already loaded. Every kind should be present so stale sets are cleared.
+func (s *RedisService) LoadReactions(ctx context.Context, targetType, targetID string, users map[string][]string) error {
+	key := reactionsKey(targetType, targetID)
+
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			fields := []interface{}{reactionsLoadedField, 1}
+			for kind, userIDs := range users {
+				usersKey := reactionUsersKey(targetType, targetID, kind)
+				pipe.Del(ctx, usersKey)
+				if len(userIDs) == 0 {
+					continue
+				}
+
+				members := make([]interface{}, len(userIDs))
+				for i, id := range userIDs {
+					members[i] = id
+				}
+				pipe.SAdd(ctx, usersKey, members...)
+				pipe.Expire(ctx, usersKey, reactionsTTL)
+				fields = append(fields, kind, len(userIDs))
+			}
+			pipe.HSet(ctx, key, fields...)
+			pipe.Expire(ctx, key, reactionsTTL)
+			return nil
+		})
+		return err
+	}, key)
+
+	// Someone else loaded it concurrently
+	if err == redis.TxFailedErr {
+		return nil
+	}
+	return err
 }
 
 // Session management
-func (s *RedisService) StoreSession(sessionID string, data interface{}) error {
-	key := fmt.Sprintf("session:%s", sessionID)
-	return s.Set(key, data, 24*time.Hour)
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+// sessionHashHistory is how many rotated-out refresh token hashes a session
+// remembers for reuse detection
+const sessionHashHistory = 32
+
+// rotateSessionScript swaps the refresh token hash of a session if the presented
+// one is current. A previously issued hash means a refresh token was reused, so
+// the session is revoked and dropped from its user's index; any other hash is
+// rejected and leaves the session alone. Returns 1 on rotation, 0 on reuse and
+// -1 if the session is gone or the hash was never issued for it.
+var rotateSessionScript = redis.NewScript(`
+local raw = redis.call("GET", KEYS[1])
+if not raw then
+	return -1
+end
+local session = cjson.decode(raw)
+local previous = session.previous_hashes
+if type(previous) ~= "table" then
+	previous = {}
+end
+if session.refresh_hash ~= ARGV[1] then
+	for _, hash in ipairs(previous) do
+		if hash == ARGV[1] then
+			redis.call("DEL", KEYS[1])
+			redis.call("SREM", KEYS[2], ARGV[4])
+			return 0
+		end
+	end
+	return -1
+end
+table.insert(previous, session.refresh_hash)
+while #previous > tonumber(ARGV[3]) do
+	table.remove(previous, 1)
+end
+session.previous_hashes = previous
+session.refresh_hash = ARGV[2]
+redis.call("SET", KEYS[1], cjson.encode(session), "KEEPTTL")
+return 1
+`)
+
+// StoreSession saves a session and indexes it under its user
+func (s *RedisService) StoreSession(ctx context.Context, sessionID, userID string, data interface{}, ttl time.Duration) error {
+	jsonValue, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, sessionKey(sessionID), jsonValue, ttl)
+	pipe.SAdd(ctx, userSessionsKey(userID), sessionID)
+	pipe.Expire(ctx, userSessionsKey(userID), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisService) GetSession(ctx context.Context, sessionID string, dest interface{}) error {
+	return s.Get(ctx, sessionKey(sessionID), dest)
 }
 
-func (s *RedisService) GetSession(sessionID string, dest interface{}) error {
-	key := fmt.Sprintf("session:%s", sessionID)
-	return s.Get(key, dest)
+// SessionExists reports whether a session is still active
+func (s *RedisService) SessionExists(ctx context.Context, sessionID string) (bool, error) {
+	n, err := s.client.Exists(ctx, sessionKey(sessionID)).Result()
+	return n > 0, err
 }
 
-func (s *RedisService) DeleteSession(sessionID string) error {
-	key := fmt.Sprintf("session:%s", sessionID)
-	return s.Delete(key)
+// RotateSession replaces the refresh token hash of a session (see rotateSessionScript)
+func (s *RedisService) RotateSession(ctx context.Context, sessionID, userID, currentHash, newHash string) (int64, error) {
+	keys := []string{sessionKey(sessionID), userSessionsKey(userID)}
+	return rotateSessionScript.Run(ctx, s.client, keys, currentHash, newHash, sessionHashHistory, sessionID).Int64()
+}
+
+func (s *RedisService) DeleteSession(ctx context.Context, sessionID, userID string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionID))
+	pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteUserSessions revokes every session of a user
+func (s *RedisService) DeleteUserSessions(ctx context.Context, userID string) error {
+	sessionIDs, err := s.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userSessionsKey(userID)}
+	for _, id := range sessionIDs {
+		keys = append(keys, sessionKey(id))
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// Post presence: sorted sets of "<userID>:<connectionID>" members scored by
+// expiry (unix millis). Each instance refreshes its own connections, so
+// entries from a crashed instance age out on their own.
+func presenceKey(postID string) string {
+	return fmt.Sprintf("presence:post:%s", postID)
+}
+
+// RefreshPresence marks connections (connection ID -> user ID) as viewing a
+// post for the next ttl
+func (s *RedisService) RefreshPresence(ctx context.Context, postID string, connections map[string]string, ttl time.Duration) error {
+	if len(connections) == 0 {
+		return nil
+	}
+
+	key := presenceKey(postID)
+	expiresAt := float64(time.Now().Add(ttl).UnixMilli())
+
+	members := make([]redis.Z, 0, len(connections))
+	for connectionID, userID := range connections {
+		members = append(members, redis.Z{Score: expiresAt, Member: userID + ":" + connectionID})
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisService) RemovePresence(ctx context.Context, postID, userID, connectionID string) error {
+	return s.client.ZRem(ctx, presenceKey(postID), userID+":"+connectionID).Err()
+}
+
+// GetPresentUserIDs returns the distinct users with a live connection on a
+// post, pruning expired entries
+func (s *RedisService) GetPresentUserIDs(ctx context.Context, postID string) ([]string, error) {
+	key := presenceKey(postID)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	pipe := s.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+now)
+	members := pipe.ZRange(ctx, key, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	userIDs := []string{}
+	for _, member := range members.Val() {
+		userID, _, _ := strings.Cut(member, ":")
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+// Post event streams: every sequenced WebSocket event of a post is appended to
+// a capped stream whose entry IDs are "<seq>-0", so clients can resume after
+// a reconnect from the last sequence number they saw
+const postEventsTTL = 24 * time.Hour
+
+// appendPostEventScript assigns the next sequence number and appends the event
+var appendPostEventScript = redis.NewScript(`
+local seq = redis.call("INCR", KEYS[1])
+redis.call("XADD", KEYS[2], "MAXLEN", "~", ARGV[2], seq .. "-0", "data", ARGV[1])
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+redis.call("EXPIRE", KEYS[2], ARGV[3])
+return seq
+`)
+
+// PostEvent is a sequenced event read back from a post's stream
+type PostEvent struct {
+	Seq  int64
+	Data []byte
+}
+
+func postSeqKey(postID string) string {
+	return fmt.Sprintf("ws:seq:%s", postID)
+}
+
+func postEventsKey(postID string) string {
+	return fmt.Sprintf("ws:events:%s", postID)
+}
+
+// AppendPostEvent stores an event in the post's replay buffer, keeping about
+// maxLen entries, and returns its sequence number
+func (s *RedisService) AppendPostEvent(ctx context.Context, postID string, data []byte, maxLen int64) (int64, error) {
+	keys := []string{postSeqKey(postID), postEventsKey(postID)}
+	return appendPostEventScript.Run(ctx, s.client, keys, data, maxLen, int64(postEventsTTL.Seconds())).Int64()
+}
+
+// GetPostEventsSince returns up to count events after afterSeq, oldest first,
+// along with the post's current sequence number
+func (s *RedisService) GetPostEventsSince(ctx context.Context, postID string, afterSeq, count int64) (int64, []PostEvent, error) {
+	pipe := s.client.Pipeline()
+	current := pipe.Get(ctx, postSeqKey(postID))
+	entries := pipe.XRangeN(ctx, postEventsKey(postID), fmt.Sprintf("%d-0", afterSeq+1), "+", count)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, nil, err
+	}
+
+	seq, err := current.Int64()
+	if err != nil && err != redis.Nil {
+		return 0, nil, err
+	}
+
+	events := make([]PostEvent, 0, len(entries.Val()))
+	for _, entry := range entries.Val() {
+		idSeq, _, _ := strings.Cut(entry.ID, "-")
+		eventSeq, err := strconv.ParseInt(idSeq, 10, 64)
+		if err != nil {
+			continue
+		}
+		data, _ := entry.Values["data"].(string)
+		events = append(events, PostEvent{Seq: eventSeq, Data: []byte(data)})
+	}
+
+	return seq, events, nil
+}
+
+// Trending hashtags: a sorted set of tag -> uses per one-minute bucket. The
+// sliding window is the union of the last TrendingWindow of buckets, cached
+// briefly so readers do not recompute it on every request.
+const (
+	TrendingWindow   = time.Hour
+	trendingBucket   = time.Minute
+	trendingCacheTTL = 30 * time.Second
+	trendingCacheKey = "trending:hashtags"
+	// trendingComputedKey marks the cached window as fresh. An empty union
+	// deletes trendingCacheKey, so its existence alone cannot tell an empty
+	// window from a missing one.
+	trendingComputedKey = "trending:hashtags:computed"
+)
+
+func trendingBucketKey(t time.Time) string {
+	return fmt.Sprintf("trending:hashtags:%d", t.Unix()/int64(trendingBucket/time.Second))
+}
+
+// IncrementHashtags counts one use of each tag in the current bucket
+func (s *RedisService) IncrementHashtags(ctx context.Context, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	key := trendingBucketKey(time.Now())
+
+	pipe := s.client.TxPipeline()
+	for _, tag := range tags {
+		pipe.ZIncrBy(ctx, key, 1, tag)
+	}
+	pipe.Expire(ctx, key, TrendingWindow+trendingBucket)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetTrendingHashtags returns up to limit tags used most within the trending window, most used first
+func (s *RedisService) GetTrendingHashtags(ctx context.Context, limit int64) ([]redis.Z, error) {
+	n, err := s.client.Exists(ctx, trendingComputedKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		now := time.Now()
+		keys := make([]string, 0, TrendingWindow/trendingBucket)
+		for t := now.Add(-TrendingWindow + trendingBucket); !t.After(now); t = t.Add(trendingBucket) {
+			keys = append(keys, trendingBucketKey(t))
+		}
+
+		pipe := s.client.TxPipeline()
+		pipe.ZUnionStore(ctx, trendingCacheKey, &redis.ZStore{Keys: keys})
+		pipe.Expire(ctx, trendingCacheKey, trendingCacheTTL)
+		pipe.Set(ctx, trendingComputedKey, 1, trendingCacheTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.client.ZRevRangeWithScores(ctx, trendingCacheKey, 0, limit-1).Result()
 }
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// maxSearchQueryLength bounds search queries in characters
+const maxSearchQueryLength = 200
+
+var (
+	ErrInvalidSearchQuery = errors.New("search query must be between 1 and 200 characters")
+	ErrInvalidSearchType  = errors.New("search type must be posts, users or messages")
+)
+
+type SearchService struct {
+	repo            *repository.SearchRepository
+	postRepo        *repository.PostRepository
+	messageRepo     *repository.MessageRepository
+	userRepo        *repository.UserRepository
+	reactionService *ReactionService
+}
+
+func NewSearchService(repo *repository.SearchRepository, postRepo *repository.PostRepository, messageRepo *repository.MessageRepository, userRepo *repository.UserRepository, reactionService *ReactionService) *SearchService {
+	return &SearchService{
+		repo:            repo,
+		postRepo:        postRepo,
+		messageRepo:     messageRepo,
+		userRepo:        userRepo,
+		reactionService: reactionService,
+	}
+}
+
+// Search returns a page of posts, messages or users matching the query, best
+// match first. searchType defaults to posts.
+func (s *SearchService) Search(ctx context.Context, query, searchType string, cursor *model.SearchCursor, limit int) (*model.SearchPage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return nil, ErrInvalidSearchQuery
+	}
+	if searchType == "" {
+		searchType = model.SearchPosts
+	}
+	limit = model.ClampPageSize(limit)
+
+	// Fetch one extra hit to know whether another page exists
+	var hits []*model.SearchHit
+	var err error
+	switch searchType {
+	case model.SearchPosts:
+		hits, err = s.repo.SearchPosts(query, cursor, limit+1)
+	case model.SearchMessages:
+		hits, err = s.repo.SearchMessages(query, cursor, limit+1)
+	case model.SearchUsers:
+		hits, err = s.repo.SearchUsers(query, cursor, limit+1)
+	default:
+		return nil, ErrInvalidSearchType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.SearchPage{Type: searchType, Results: []*model.SearchResult{}}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		page.NextCursor = hits[limit-1].Cursor().Encode()
+	}
+
+	ids := make([]uuid.UUID, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+
+	results := make(map[uuid.UUID]*model.SearchResult, len(hits))
+	switch searchType {
+	case model.SearchPosts:
+		posts, err := s.postRepo.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		s.reactionService.AttachToPosts(ctx, posts)
+		for _, post := range posts {
+			results[post.ID] = &model.SearchResult{Post: post}
+		}
+	case model.SearchMessages:
+		messages, err := s.messageRepo.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		s.reactionService.AttachToMessages(ctx, messages)
+		for _, message := range messages {
+			results[message.ID] = &model.SearchResult{Message: message}
+		}
+	case model.SearchUsers:
+		users, err := s.userRepo.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			results[user.ID] = &model.SearchResult{User: &model.SearchUser{
+				ID:            user.ID,
+				Username:      user.Username,
+				FollowerCount: user.FollowerCount,
+			}}
+		}
+	}
+
+	// Keep the database's ranking; rows deleted since the search are skipped
+	for _, hit := range hits {
+		if result, ok := results[hit.ID]; ok {
+			result.Rank = hit.Rank
+			result.Snippet = hit.Snippet
+			page.Results = append(page.Results, result)
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/model"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// SessionService issues access/refresh token pairs backed by server-side
+// sessions. Refresh tokens have the form "<session id>.<secret>" and rotate on
+// every use; presenting an already-rotated token revokes the whole session,
+// while a token that was never issued is only rejected.
+type SessionService struct {
+	redisService *RedisService
+	userRepo     *repository.UserRepository
+	keys         *utils.KeyManager
+	cfg          config.JWTConfig
+}
+
+func NewSessionService(redisService *RedisService, userRepo *repository.UserRepository, keys *utils.KeyManager, cfg config.JWTConfig) *SessionService {
+	return &SessionService{
+		redisService: redisService,
+		userRepo:     userRepo,
+		keys:         keys,
+		cfg:          cfg,
+	}
+}
+
+// Create starts a new session for a user who just authenticated
+func (s *SessionService) Create(ctx context.Context, user *model.User) (*model.TokenPair, error) {
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &model.Session{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		RefreshHash: utils.HashToken(secret),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.cfg.RefreshTokenTTL),
+	}
+
+	err = s.redisService.StoreSession(ctx, session.ID, user.ID.String(), session, s.cfg.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, session.ID, secret)
+}
+
+// Refresh rotates a refresh token and issues a new token pair
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var session model.Session
+	if err := s.redisService.GetSession(ctx, sessionID, &session); err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newSecret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.redisService.RotateSession(ctx, sessionID, session.UserID.String(), utils.HashToken(secret), utils.HashToken(newSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	switch result {
+	case -1:
+		return nil, ErrInvalidRefreshToken
+	case 0:
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		s.redisService.DeleteSession(ctx, sessionID, session.UserID.String())
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issue(user, sessionID, newSecret)
+}
+
+func (s *SessionService) issue(user *model.User, sessionID, secret string) (*model.TokenPair, error) {
+	accessToken, err := utils.GenerateJWT(user, sessionID, s.keys, s.cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: sessionID + "." + secret,
+		ExpiresIn:    int64(s.cfg.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Revoke ends a single session
+func (s *SessionService) Revoke(ctx context.Context, sessionID string, userID uuid.UUID) error {
+	return s.redisService.DeleteSession(ctx, sessionID, userID.String())
+}
+
+// RevokeAll ends every session of a user
+func (s *SessionService) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	return s.redisService.DeleteUserSessions(ctx, userID.String())
+}
+
+// IsActive reports whether the session behind an access token is still valid
+func (s *SessionService) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	return s.redisService.SessionExists(ctx, sessionID)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"social-media-app/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func TestRotateSession(t *testing.T) {
+	s, mr := newTestRedisService(t, "json")
+	ctx := context.Background()
+	userID := uuid.New()
+	session := &model.Session{ID: uuid.New().String(), UserID: userID, RefreshHash: "h0"}
+	if err := s.StoreSession(ctx, session.ID, userID.String(), session, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	rotate := func(current, next string) int64 {
+		t.Helper()
+		result, err := s.RotateSession(ctx, session.ID, userID.String(), current, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if got := rotate("h0", "h1"); got != 1 {
+		t.Fatalf("current hash: got %d, want 1", got)
+	}
+	if got := rotate("h1", "h2"); got != 1 {
+		t.Fatalf("rotated hash: got %d, want 1", got)
+	}
+
+	// A hash that was never issued is rejected without touching the session
+	if got := rotate("guess", "h3"); got != -1 {
+		t.Fatalf("unknown hash: got %d, want -1", got)
+	}
+	var stored model.Session
+	if err := s.GetSession(ctx, session.ID, &stored); err != nil {
+		t.Fatalf("session lost after a wrong hash: %v", err)
+	}
+	if stored.RefreshHash != "h2" || len(stored.PreviousHashes) != 2 {
+		t.Fatalf("got %+v", stored)
+	}
+
+	// Replaying a rotated-out hash revokes the session everywhere
+	if got := rotate("h0", "h3"); got != 0 {
+		t.Fatalf("reused hash: got %d, want 0", got)
+	}
+	if mr.Exists(sessionKey(session.ID)) {
+		t.Fatal("session kept after reuse")
+	}
+	if ok, _ := mr.SIsMember(userSessionsKey(userID.String()), session.ID); ok {
+		t.Fatal("session kept in the user's index after reuse")
+	}
+	if got := rotate("h2", "h3"); got != -1 {
+		t.Fatalf("revoked session: got %d, want -1", got)
+	}
+}
+
+func TestRotateSessionBoundsHistory(t *testing.T) {
+	s, _ := newTestRedisService(t, "json")
+	ctx := context.Background()
+	userID := uuid.New()
+	session := &model.Session{ID: uuid.New().String(), UserID: userID, RefreshHash: "0"}
+	if err := s.StoreSession(ctx, session.ID, userID.String(), session, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	const rotations = sessionHashHistory + 5
+	for i := 0; i < rotations; i++ {
+		result, err := s.RotateSession(ctx, session.ID, userID.String(), strconv.Itoa(i), strconv.Itoa(i+1))
+		if err != nil || result != 1 {
+			t.Fatalf("rotation %d: got %d, %v", i, result, err)
+		}
+	}
+
+	var stored model.Session
+	if err := s.GetSession(ctx, session.ID, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.PreviousHashes) != sessionHashHistory || stored.PreviousHashes[0] != strconv.Itoa(rotations-sessionHashHistory) {
+		t.Fatalf("kept %d hashes starting at %q", len(stored.PreviousHashes), stored.PreviousHashes[0])
+	}
+}
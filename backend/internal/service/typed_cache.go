@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is a typed view of the cache for one kind of value. Entries live under
+// "<version>:<codec>:<namespace>:<id>" and are read and written through fetch,
+// so they get its stampede protection, L1 tier and safe invalidation.
+type Cache[K comparable, V any] struct {
+	redis     *RedisService
+	namespace string
+	ttl       time.Duration
+	keyOf     func(K) string
+}
+
+// NewCache returns a cache of values under namespace, keyed by keyOf(k) and kept for ttl
+func NewCache[K comparable, V any](redisService *RedisService, namespace string, ttl time.Duration, keyOf func(K) string) *Cache[K, V] {
+	return &Cache[K, V]{
+		redis:     redisService,
+		namespace: namespace,
+		ttl:       ttl,
+		keyOf:     keyOf,
+	}
+}
+
+// Key returns the Redis key of k
+func (c *Cache[K, V]) Key(k K) string {
+	return c.redis.cacheKey(c.namespace, c.keyOf(k))
+}
+
+// Get returns the cached value of k, calling load on a miss. Empty results
+// (not found, no rows) are cached for emptyCacheTTL instead of the full TTL.
+// Cancelling ctx abandons the wait, but not a load other callers share.
+func (c *Cache[K, V]) Get(ctx context.Context, k K, load func(ctx context.Context) (value V, empty bool, err error)) (V, error) {
+	var value V
+	data, err := c.redis.fetch(ctx, c.namespace, c.Key(k), c.ttl, func(ctx context.Context) ([]byte, bool, error) {
+		value, empty, err := load(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		data, err := c.redis.codec.Marshal(value)
+		return data, empty, err
+	})
+	if err != nil {
+		return value, err
+	}
+
+	// Every caller decodes its own copy, so sharing a load never shares a value
+	err = c.redis.codec.Unmarshal(data, &value)
+	return value, err
+}
+
+// GetMany returns the values of ks in order, loading all misses with a single
+// call to load. Keys load leaves out are cached as empty, with the zero value.
+func (c *Cache[K, V]) GetMany(ctx context.Context, ks []K, load func(ctx context.Context, ks []K) (map[K]V, error)) ([]V, error) {
+	keys := make([]string, len(ks))
+	for i, k := range ks {
+		keys[i] = c.Key(k)
+	}
+
+	data, err := c.redis.fetchMany(ctx, c.namespace, keys, c.ttl, func(ctx context.Context, missing []int) ([][]byte, []bool, error) {
+		missingKs := make([]K, len(missing))
+		for j, i := range missing {
+			missingKs[j] = ks[i]
+		}
+		loaded, err := load(ctx, missingKs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data := make([][]byte, len(missingKs))
+		empty := make([]bool, len(missingKs))
+		for j, k := range missingKs {
+			value, ok := loaded[k]
+			if data[j], err = c.redis.codec.Marshal(value); err != nil {
+				return nil, nil, err
+			}
+			empty[j] = !ok
+		}
+		return data, empty, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]V, len(ks))
+	for i := range data {
+		if err := c.redis.codec.Unmarshal(data[i], &values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Invalidate removes the entry of k on every instance
+func (c *Cache[K, V]) Invalidate(ctx context.Context, k K) error {
+	return c.redis.invalidate(ctx, c.Key(k))
+}
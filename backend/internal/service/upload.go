@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"path/filepath"
 	"social-media-app/internal/model"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -46,3 +48,25 @@ func (s *UploadService) UploadImage(file io.Reader, filename string, contentType
 		Filename: uniqueFilename,
 	}, nil
 }
+
+// DeleteImage removes an image previously returned by UploadImage. URLs that
+// do not point into the bucket (e.g. external images) are ignored.
+func (s *UploadService) DeleteImage(imageURL string) error {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return nil
+	}
+
+	prefix := "/" + s.bucketName + "/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return nil
+	}
+
+	ctx := context.Background()
+	err = s.minioClient.RemoveObject(ctx, s.bucketName, strings.TrimPrefix(u.Path, prefix), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
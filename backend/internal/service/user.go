@@ -1,25 +1,25 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"social-media-app/internal/metrics"
 	"social-media-app/internal/model"
 	"social-media-app/internal/repository"
-	"social-media-app/internal/utils"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	repo      *repository.UserRepository
-	jwtSecret string
+	repo           *repository.UserRepository
+	sessionService *SessionService
 }
 
-func NewUserService(repo *repository.UserRepository, jwtSecret string) *UserService {
+func NewUserService(repo *repository.UserRepository, sessionService *SessionService) *UserService {
 	return &UserService{
-		repo:      repo,
-		jwtSecret: jwtSecret,
+		repo:           repo,
+		sessionService: sessionService,
 	}
 }
 
@@ -58,7 +58,7 @@ func (s *UserService) Register(req *model.RegisterRequest) (*model.User, error)
 	return user, nil
 }
 
-func (s *UserService) Login(req *model.LoginRequest) (*model.LoginResponse, error) {
+func (s *UserService) Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error) {
 	user, err := s.repo.GetByEmail(req.Email)
 	if err != nil {
 		return nil, err
@@ -72,15 +72,16 @@ func (s *UserService) Login(req *model.LoginRequest) (*model.LoginResponse, erro
 		return nil, errors.New("invalid credentials")
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user, s.jwtSecret)
+	// Start a session and issue the token pair
+	tokens, err := s.sessionService.Create(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
 	return &model.LoginResponse{
-		Token: token,
-		User:  *user,
+		Token:     tokens.AccessToken,
+		TokenPair: *tokens,
+		User:      *user,
 	}, nil
 }
 
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxHashtagLength bounds the length of a hashtag in characters
+const MaxHashtagLength = 64
+
+var (
+	// Usernames may contain '.' and '-', but not at the end, so "@alice." is alice
+	mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_]+(?:[.-][\p{L}\p{N}_]+)*)`)
+	hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+)
+
+// EntityMatch is a mention or hashtag found in a text. Value excludes the
+// leading '@' or '#'; Start and End are offsets in code points, End exclusive.
+type EntityMatch struct {
+	Value string
+	Start int
+	End   int
+}
+
+// ParseMentions finds the @username mentions in a text
+func ParseMentions(text string) []EntityMatch {
+	return parseEntities(text, mentionPattern, func(value string) (string, bool) {
+		return value, true
+	})
+}
+
+// ParseHashtags finds the #hashtags in a text. Values are normalized with
+// NormalizeHashtag; purely numeric tags such as "#1" are not hashtags.
+func ParseHashtags(text string) []EntityMatch {
+	return parseEntities(text, hashtagPattern, func(value string) (string, bool) {
+		tag := NormalizeHashtag(value)
+		return tag, tag != ""
+	})
+}
+
+// NormalizeHashtag lowercases a hashtag and strips its '#'. It returns "" if
+// the result is not a valid hashtag.
+func NormalizeHashtag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxHashtagLength {
+		return ""
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsNumber(r) || r == '_':
+		default:
+			return ""
+		}
+	}
+	if !hasLetter {
+		return ""
+	}
+	return tag
+}
+
+// parseEntities returns the matches of pattern that start a word, so that
+// e-mail addresses and URL fragments are not picked up
+func parseEntities(text string, pattern *regexp.Regexp, accept func(string) (string, bool)) []EntityMatch {
+	var matches []EntityMatch
+	for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+			if unicode.IsLetter(prev) || unicode.IsNumber(prev) || strings.ContainsRune("_@#&/", prev) {
+				continue
+			}
+		}
+
+		value, ok := accept(text[loc[2]:loc[3]])
+		if !ok {
+			continue
+		}
+
+		start := utf8.RuneCountInString(text[:loc[0]])
+		matches = append(matches, EntityMatch{
+			Value: value,
+			Start: start,
+			End:   start + utf8.RuneCountInString(text[loc[0]:loc[1]]),
+		})
+	}
+	return matches
+}
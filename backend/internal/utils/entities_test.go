@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []EntityMatch
+	}{
+		{"plain", "hi @alice", []EntityMatch{{"alice", 3, 9}}},
+		{"start of text", "@alice hi", []EntityMatch{{"alice", 0, 6}}},
+		{"email address", "mail a@b or a@b.com", nil},
+		{"double at", "@@alice", nil},
+		{"after hash", "#@alice", nil},
+		{"in url", "example.com/@alice", nil},
+		{"trailing period", "thanks @alice.", []EntityMatch{{"alice", 7, 13}}},
+		{"trailing dash", "@bob- and @carol!", []EntityMatch{{"bob", 0, 4}, {"carol", 10, 16}}},
+		{"inner punctuation", "@jean-luc.picard, hi", []EntityMatch{{"jean-luc.picard", 0, 16}}},
+		{"after punctuation", "(@alice)", []EntityMatch{{"alice", 1, 7}}},
+		{"multi-byte before", "héllo 🎉 @zoë", []EntityMatch{{"zoë", 8, 12}}},
+		{"multi-byte name", "@日本 @アリス", []EntityMatch{{"日本", 0, 3}, {"アリス", 4, 8}}},
+		{"none", "no mentions here", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []EntityMatch
+	}{
+		{"plain", "learning #go", []EntityMatch{{"go", 9, 12}}},
+		{"lowercased", "#GoLang", []EntityMatch{{"golang", 0, 7}}},
+		{"double hash", "##tag", nil},
+		{"inside word", "c#sharp and a#b", nil},
+		{"after at", "@#tag", nil},
+		{"url fragment", "example.com/page#section", nil},
+		{"html entity", "&#39;", nil},
+		{"numeric", "#1 and #2024", nil},
+		{"letters and digits", "#web3 #2024_recap", []EntityMatch{{"web3", 0, 5}, {"2024_recap", 6, 17}}},
+		{"trailing punctuation", "so #fun! #yes.", []EntityMatch{{"fun", 3, 7}, {"yes", 9, 13}}},
+		{"multi-byte before", "ça 🎉 #café", []EntityMatch{{"café", 5, 10}}},
+		{"multi-byte tag", "#日本語 #Ünïcode", []EntityMatch{{"日本語", 0, 4}, {"ünïcode", 5, 13}}},
+		{"none", "no tags here", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHashtags(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHashtags(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"#Go", "go"},
+		{"go_lang", "go_lang"},
+		{"#", ""},
+		{"123", ""},
+		{"go-lang", ""},
+		{"#Ärger", "ärger"},
+		{strings.Repeat("é", MaxHashtagLength), strings.Repeat("é", MaxHashtagLength)},
+		{strings.Repeat("é", MaxHashtagLength+1), ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeHashtag(tt.tag); got != tt.want {
+			t.Errorf("NormalizeHashtag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
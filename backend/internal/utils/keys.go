@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"social-media-app/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// hmacKeyID is the kid used for tokens signed with the shared secret fallback
+const hmacKeyID = "hmac"
+
+// placeholderSecret is the example secret from the deployment files, which
+// must never sign real tokens
+const placeholderSecret = "your-super-secret-jwt-key-change-this-in-production"
+
+// verificationKey is a key accepted when validating tokens
+type verificationKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey // nil for HMAC
+	secret []byte           // HMAC only
+}
+
+// KeyManager signs tokens with the current key and verifies tokens against
+// every active key, identified by the kid header. This allows rotation without
+// downtime: a new key is introduced for signing while the previous public keys
+// stay in the verification set until their tokens expire.
+type KeyManager struct {
+	signingID     string
+	signingMethod jwt.SigningMethod
+	signingKey    interface{}
+	keys          map[string]*verificationKey
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeyManager loads the signing key and extra verification keys from PEM
+// files. Without a signing key file it falls back to HS256 with the shared
+// secret, and refuses to start if that secret is unset or the placeholder.
+func LoadKeyManager(cfg *config.JWTConfig) (*KeyManager, error) {
+	m := &KeyManager{keys: make(map[string]*verificationKey)}
+
+	if cfg.SigningKeyFile == "" {
+		switch cfg.Secret {
+		case "":
+			return nil, errors.New("no signing key: set JWT_SIGNING_KEY_FILE, or JWT_SECRET for development")
+		case placeholderSecret:
+			return nil, errors.New("JWT_SECRET is the example placeholder: set JWT_SIGNING_KEY_FILE or a random JWT_SECRET")
+		}
+		log.Println("⚠️  JWT: no signing key file configured, falling back to HS256 with shared secret")
+		m.signingID = hmacKeyID
+		m.signingMethod = jwt.SigningMethodHS256
+		m.signingKey = []byte(cfg.Secret)
+		m.keys[hmacKeyID] = &verificationKey{method: jwt.SigningMethodHS256, secret: []byte(cfg.Secret)}
+		return m, nil
+	}
+
+	signer, err := loadPrivateKey(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newVerificationKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	m.signingID, err = keyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	m.signingMethod = key.method
+	m.signingKey = signer
+	m.keys[m.signingID] = key
+
+	for _, path := range cfg.VerificationKeyFiles {
+		public, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return nil, err
+		}
+		id, err := keyID(public)
+		if err != nil {
+			return nil, err
+		}
+		m.keys[id] = key
+	}
+
+	log.Printf("🔑 JWT: signing with %s key %s, %d verification key(s)", m.signingMethod.Alg(), m.signingID, len(m.keys))
+	return m, nil
+}
+
+// Sign returns a signed token carrying the current key's kid
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.signingMethod, claims)
+	token.Header["kid"] = m.signingID
+	return token.SignedString(m.signingKey)
+}
+
+// Keyfunc resolves the verification key of a token by kid and rejects
+// algorithms that do not match that key
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing algorithm %q", token.Method.Alg())
+	}
+
+	if key.secret != nil {
+		return key.secret, nil
+	}
+	return key.public, nil
+}
+
+// ValidMethods lists the algorithms of the active keys, for jwt.WithValidMethods
+func (m *KeyManager) ValidMethods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range m.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public verification keys. Shared secrets are never published.
+func (m *KeyManager) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for id, key := range m.keys {
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return jwks
+}
+
+func newVerificationKey(public crypto.PublicKey) (*verificationKey, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return &verificationKey{method: jwt.SigningMethodRS256, public: public}, nil
+	case ed25519.PublicKey:
+		return &verificationKey{method: jwt.SigningMethodEdDSA, public: public}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (expected RSA or Ed25519)", public)
+	}
+}
+
+// keyID derives a stable kid from the SHA-256 of the public key
+func keyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
+
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return signer, nil
+}
+
+// loadPublicKey accepts a public key or a private key file
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+		}
+		return key, nil
+	default:
+		signer, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"social-media-app/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeKey(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func parse(m *KeyManager, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, m.Keyfunc, jwt.WithValidMethods(m.ValidMethods()))
+	return err
+}
+
+// rotatedKeys returns a manager signing with a new Ed25519 key that still
+// verifies tokens of the previous RSA key, and a manager for that old key
+func rotatedKeys(t *testing.T) (current, previous *KeyManager, oldKey *rsa.PrivateKey, newKey ed25519.PrivateKey) {
+	t.Helper()
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newKey, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPath, newPath := writeKey(t, oldKey), writeKey(t, newKey)
+
+	previous, err = LoadKeyManager(&config.JWTConfig{SigningKeyFile: oldPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err = LoadKeyManager(&config.JWTConfig{SigningKeyFile: newPath, VerificationKeyFiles: []string{oldPath}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return current, previous, oldKey, newKey
+}
+
+func TestKeyManagerVerifiesByKid(t *testing.T) {
+	current, previous, oldKey, newKey := rotatedKeys(t)
+
+	token, err := current.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKid, _ := keyID(newKey.Public())
+	if kid := parsed.Header["kid"]; kid != wantKid {
+		t.Fatalf("kid = %v, want %s", kid, wantKid)
+	}
+	if parsed.Method != jwt.SigningMethodEdDSA {
+		t.Fatalf("alg = %s, want EdDSA", parsed.Method.Alg())
+	}
+	if err := parse(current, token); err != nil {
+		t.Fatalf("token of the signing key rejected: %v", err)
+	}
+
+	// Tokens signed before the rotation still verify
+	oldToken, err := previous.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, oldToken); err != nil {
+		t.Fatalf("token of the previous key rejected: %v", err)
+	}
+	if previous.signingID == current.signingID {
+		t.Fatal("different keys share a kid")
+	}
+	if again, _ := keyID(oldKey.Public()); again != previous.signingID {
+		t.Fatalf("kid is not stable: %s != %s", again, previous.signingID)
+	}
+}
+
+func TestKeyManagerRejectsUnknownKid(t *testing.T) {
+	current, _, _, _ := rotatedKeys(t)
+
+	_, stranger, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadKeyManager(&config.JWTConfig{SigningKeyFile: writeKey(t, stranger)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := other.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, token); err == nil {
+		t.Fatal("token of an unknown key accepted")
+	}
+
+	// A known kid does not make another key's signature valid
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+	forged.Header["kid"] = current.signingID
+	signed, err := forged.SignedString(stranger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, signed); err == nil {
+		t.Fatal("token signed by another key under a known kid accepted")
+	}
+
+	// Tokens without a kid are rejected too
+	unkeyed := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+	signed, err = unkeyed.SignedString(current.signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, signed); err == nil {
+		t.Fatal("token without kid accepted")
+	}
+}
+
+func TestKeyManagerRejectsUnexpectedAlg(t *testing.T) {
+	current, previous, oldKey, _ := rotatedKeys(t)
+
+	// Algorithm confusion: HS256 keyed with the published RSA public key
+	publicDER, err := x509.MarshalPKIXPublicKey(oldKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = previous.signingID
+	signed, err := forged.SignedString(publicDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, signed); err == nil {
+		t.Fatal("HS256 token under an RSA kid accepted")
+	}
+
+	// An algorithm valid for another active key is still rejected for this one
+	forged = jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+	forged.Header["kid"] = current.signingID
+	signed, err = forged.SignedString(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, signed); err == nil {
+		t.Fatal("RS256 token under an Ed25519 kid accepted")
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims())
+	unsigned.Header["kid"] = current.signingID
+	signed, err = unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(current, signed); err == nil {
+		t.Fatal("unsigned token accepted")
+	}
+}
+
+func TestKeyManagerJWKS(t *testing.T) {
+	current, previous, oldKey, newKey := rotatedKeys(t)
+
+	jwks := current.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(jwks.Keys))
+	}
+
+	byKid := make(map[string]JWK)
+	for _, key := range jwks.Keys {
+		if key.Use != "sig" {
+			t.Errorf("key %s use = %q, want sig", key.Kid, key.Use)
+		}
+		byKid[key.Kid] = key
+	}
+
+	ed, ok := byKid[current.signingID]
+	if !ok {
+		t.Fatal("signing key missing from JWKS")
+	}
+	if ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" {
+		t.Errorf("unexpected Ed25519 JWK %+v", ed)
+	}
+	if x, _ := base64.RawURLEncoding.DecodeString(ed.X); string(x) != string(newKey.Public().(ed25519.PublicKey)) {
+		t.Error("Ed25519 JWK does not encode the public key")
+	}
+
+	rsaKey, ok := byKid[previous.signingID]
+	if !ok {
+		t.Fatal("verification key missing from JWKS")
+	}
+	if rsaKey.Kty != "RSA" || rsaKey.Alg != "RS256" {
+		t.Errorf("unexpected RSA JWK %+v", rsaKey)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(rsaKey.N)
+	e, _ := base64.RawURLEncoding.DecodeString(rsaKey.E)
+	if new(big.Int).SetBytes(n).Cmp(oldKey.N) != 0 || int(new(big.Int).SetBytes(e).Int64()) != oldKey.E {
+		t.Error("RSA JWK does not encode the public key")
+	}
+}
+
+func TestLoadKeyManagerSecretFallback(t *testing.T) {
+	for _, secret := range []string{"", placeholderSecret} {
+		if _, err := LoadKeyManager(&config.JWTConfig{Secret: secret}); err == nil {
+			t.Errorf("started with secret %q", secret)
+		}
+	}
+
+	m, err := LoadKeyManager(&config.JWTConfig{Secret: "development-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := m.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(m, token); err != nil {
+		t.Fatalf("HS256 token rejected: %v", err)
+	}
+	if keys := m.JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("shared secret published in JWKS: %+v", keys)
+	}
+}
@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"social-media-app/internal/metrics"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Close codes sent when authentication fails (private-use range 4000-4999)
+const (
+	CloseUnauthorized = 4001
+	CloseTokenExpired = 4002
+	CloseAuthTimeout  = 4003
+)
+
+// authSubprotocol is the Sec-WebSocket-Protocol marker preceding a token,
+// e.g. new WebSocket(url, ["access_token", token])
+const authSubprotocol = "access_token"
+
+// Authenticator validates an access token and returns the user it belongs to
+type Authenticator func(ctx context.Context, token string) (uuid.UUID, error)
+
+// tokenFromRequest reads a token from the query string or the Sec-WebSocket-Protocol
+// header. It reports whether the subprotocol must be echoed back in the handshake.
+func tokenFromRequest(r *http.Request) (string, bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, false
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == authSubprotocol {
+			return protocols[i+1], true
+		}
+	}
+
+	return "", false
+}
+
+// readAuthFrame waits up to timeout for {"type":"auth","token":"..."}
+func readAuthFrame(conn *websocket.Conn, timeout time.Duration) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return "", err
+	}
+
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != FrameAuth || strings.TrimSpace(msg.Token) == "" {
+		return "", errors.New("expected auth frame")
+	}
+
+	return msg.Token, nil
+}
+
+// closeWithCode sends a close frame and closes the connection
+func closeWithCode(conn *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// authenticateConn resolves the user of a freshly upgraded connection,
+// closing it with an appropriate code on failure
+func (h *Hub) authenticateConn(conn *websocket.Conn, token string) (uuid.UUID, bool) {
+	// Unauthenticated peers get the same frame size limit as everyone else
+	conn.SetReadLimit(h.cfg.MaxMessageSize)
+
+	if token == "" {
+		var err error
+		token, err = readAuthFrame(conn, h.cfg.AuthTimeout)
+		if err != nil {
+			metrics.IncrementWebSocketDisconnects("auth_timeout")
+			closeWithCode(conn, CloseAuthTimeout, "authentication required")
+			return uuid.Nil, false
+		}
+	}
+
+	// The handshake request is over by now, so bound the check on its own
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.AuthTimeout)
+	defer cancel()
+
+	userID, err := h.authenticate(ctx, token)
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		metrics.IncrementWebSocketDisconnects("token_expired")
+		closeWithCode(conn, CloseTokenExpired, "token expired")
+		return uuid.Nil, false
+	case err != nil:
+		metrics.IncrementWebSocketDisconnects("unauthorized")
+		closeWithCode(conn, CloseUnauthorized, "invalid token")
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
@@ -1,10 +1,18 @@
 package websocket
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/metrics"
+	"social-media-app/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -23,101 +31,550 @@ type Client struct {
 	Conn   *websocket.Conn
 	Send   chan []byte
 	Hub    *Hub
+
+	// ctx lives as long as the connection; cancel ends it when the read pump exits
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Posts this client is subscribed to (owned by the hub goroutine)
+	posts map[string]bool
+
+	// closeOnce ensures only the first close reason is recorded
+	closeOnce sync.Once
+
+	// Posts whose missed events are being replayed (owned by the hub goroutine)
+	replaying map[string]*replayState
+
+	// Last typing event sent per post (owned by the read pump)
+	lastTyping map[string]time.Time
+
+	// Delivery counters, reported when the client is removed
+	delivered atomic.Int64
+	dropped   atomic.Int64
 }
 
+// Slow-consumer policies, applied when a client's send queue is full
+const (
+	// PolicyDropOldest discards the oldest queued message to make room
+	PolicyDropOldest = "drop_oldest"
+	// PolicyDropNewest discards the message being delivered
+	PolicyDropNewest = "drop_newest"
+	// PolicyDisconnect removes the client from the hub
+	PolicyDisconnect = "disconnect"
+)
+
+// Close reasons recorded in websocket_disconnects_total
+const (
+	closeReasonClient       = "client_closed"
+	closeReasonPongTimeout  = "pong_timeout"
+	closeReasonTooLarge     = "message_too_large"
+	closeReasonReadError    = "read_error"
+	closeReasonWriteError   = "write_error"
+	closeReasonSlowConsumer = "slow_consumer"
+)
+
+// frameSeparator splits messages batched into a single frame
+var frameSeparator = []byte{'\n'}
+
+// Hub routes events to subscribed clients, and to users on any of their
+// connections. The clients, posts and users maps are owned by the Run
+// goroutine and never touched elsewhere; a client's Send channel is closed
+// only by removeClient, which runs there too. Pumps and handlers talk to the
+// hub exclusively through its channels, so a client is removed exactly once
+// no matter how many parties ask for it. The hub never waits on Redis: relay
+// and presence changes are queued without blocking.
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-	mutex      sync.RWMutex
+	clients      map[*Client]bool
+	posts        map[string]map[*Client]bool
+	users        map[uuid.UUID]map[*Client]bool
+	broadcast    chan *postMessage
+	toUser       chan *userMessage
+	register     chan *Client
+	unregister   chan *Client
+	subscribe    chan *subscription
+	unsubscribe  chan *subscription
+	direct       chan *directMessage
+	typing       chan *typingRequest
+	replayDone   chan *replayResult
+	count        chan chan int
+	redisService *service.RedisService
+	relay        *relay
+	presence     *presence
+	authenticate Authenticator
+	messages     *service.MessageService
+	allowMessage RateLimit
+	cfg          config.WebSocketConfig
+
+	// Events raised on the hub goroutine, emitted after the current operation
+	pending []*Message
+}
+
+// postMessage is a payload addressed to the subscribers of a single post.
+// seq is its position in the post's event stream, or 0 if it is not sequenced.
+type postMessage struct {
+	postID string
+	seq    int64
+	data   []byte
+}
+
+// userMessage is a payload addressed to every connection of a user
+type userMessage struct {
+	userID uuid.UUID
+	data   []byte
 }
 
-type Message struct {
-	Type    string      `json:"type"`
-	PostID  string      `json:"post_id,omitempty"`
-	UserID  string      `json:"user_id,omitempty"`
-	Content interface{} `json:"content"`
+// directMessage is a payload addressed to a single client, such as a reply
+type directMessage struct {
+	client *Client
+	data   []byte
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+type subscription struct {
+	client *Client
+	postID string
+	// lastSeq, when set, asks for the events after it to be replayed
+	lastSeq *int64
+}
+
+// typingRequest is a client's typing indicator for a post; id is the frame ID to ack
+type typingRequest struct {
+	client *Client
+	postID string
+	id     string
+}
+
+// NewHub creates a hub that authenticates sockets with authenticate. When
+// redisService is non-nil, broadcasts are also relayed to hubs running in
+// other instances. Messages sent over the socket are stored through
+// messageService, subject to allowMessage; without a message service the
+// send_message frame is rejected.
+func NewHub(redisService *service.RedisService, messageService *service.MessageService, authenticate Authenticator, allowMessage RateLimit, cfg config.WebSocketConfig) *Hub {
+	h := &Hub{
+		clients:      make(map[*Client]bool),
+		posts:        make(map[string]map[*Client]bool),
+		users:        make(map[uuid.UUID]map[*Client]bool),
+		broadcast:    make(chan *postMessage),
+		toUser:       make(chan *userMessage),
+		register:     make(chan *Client),
+		unregister:   make(chan *Client),
+		subscribe:    make(chan *subscription),
+		unsubscribe:  make(chan *subscription),
+		direct:       make(chan *directMessage),
+		typing:       make(chan *typingRequest),
+		replayDone:   make(chan *replayResult),
+		count:        make(chan chan int),
+		authenticate: authenticate,
+		messages:     messageService,
+		allowMessage: allowMessage,
+		redisService: redisService,
+		cfg:          cfg,
+	}
+
+	switch cfg.SlowConsumerPolicy {
+	case PolicyDropOldest, PolicyDropNewest, PolicyDisconnect:
+	default:
+		log.Printf("⚠️  Unknown WebSocket slow-consumer policy %q, using %q", cfg.SlowConsumerPolicy, PolicyDisconnect)
+		h.cfg.SlowConsumerPolicy = PolicyDisconnect
+	}
+
+	if redisService != nil {
+		h.relay = newRelay(redisService)
+		h.presence = newPresence(redisService)
 	}
+
+	return h
 }
 
 func (h *Hub) Run() {
+	if h.relay != nil {
+		go h.relay.run(h)
+	}
+	if h.presence != nil {
+		go h.presence.run()
+	}
+
 	for {
 		select {
 		case client := <-h.register:
-			h.mutex.Lock()
 			h.clients[client] = true
-			h.mutex.Unlock()
+			h.addUserClient(client)
+			metrics.IncrementWebSocketConnections()
 			log.Printf("Client %s connected (User: %s)", client.ID, client.UserID)
 
 		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.Send)
-				log.Printf("Client %s disconnected", client.ID)
+			h.removeClient(client)
+
+		case sub := <-h.subscribe:
+			if h.addSubscription(sub.client, sub.postID) && sub.lastSeq != nil && h.redisService != nil {
+				if sub.client.replaying == nil {
+					sub.client.replaying = make(map[string]*replayState)
+				}
+				sub.client.replaying[sub.postID] = &replayState{}
+				go h.fetchReplay(sub.client, sub.postID, *sub.lastSeq)
 			}
-			h.mutex.Unlock()
+
+		case sub := <-h.unsubscribe:
+			h.removeSubscription(sub.client, sub.postID)
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
-			for client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.clients, client)
+			for client := range h.posts[message.postID] {
+				if state, ok := client.replaying[message.postID]; ok {
+					state.live = append(state.live, message)
+					continue
 				}
+				h.deliver(client, message.data)
+			}
+
+		case message := <-h.toUser:
+			for client := range h.users[message.userID] {
+				h.deliver(client, message.data)
+			}
+
+		case result := <-h.replayDone:
+			h.finishReplay(result)
+
+		case message := <-h.direct:
+			if _, ok := h.clients[message.client]; ok {
+				h.deliver(message.client, message.data)
 			}
-			h.mutex.RUnlock()
+
+		case req := <-h.typing:
+			h.handleTyping(req)
+
+		case reply := <-h.count:
+			reply <- len(h.clients)
 		}
+
+		h.flushPending()
 	}
 }
 
-func (h *Hub) BroadcastToPost(postID string, message interface{}) {
-	msg := Message{
-		Type:    "new_message",
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	reply := make(chan int)
+	h.count <- reply
+	return <-reply
+}
+
+// deliver queues data for a client without blocking, applying the
+// slow-consumer policy when its queue is full. Runs on the hub goroutine.
+func (h *Hub) deliver(client *Client, data []byte) {
+	// The client may have been disconnected earlier in the same operation
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	select {
+	case client.Send <- data:
+		client.delivered.Add(1)
+		return
+	default:
+	}
+
+	policy := h.cfg.SlowConsumerPolicy
+	switch policy {
+	case PolicyDropOldest:
+		// The write pump may drain the queue concurrently, so neither
+		// operation is allowed to block
+		select {
+		case <-client.Send:
+		default:
+		}
+		select {
+		case client.Send <- data:
+			client.delivered.Add(1)
+		default:
+		}
+	case PolicyDisconnect:
+		client.recordClose(closeReasonSlowConsumer)
+		h.removeClient(client)
+	}
+
+	client.dropped.Add(1)
+	metrics.IncrementWebSocketDroppedMessages(policy)
+}
+
+// removeClient drops a client and all of its subscriptions and closes its
+// send queue. Removing a client that is already gone is a no-op. Runs on the
+// hub goroutine.
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	for postID := range client.posts {
+		h.removeSubscription(client, postID)
+	}
+	h.removeUserClient(client)
+	delete(h.clients, client)
+	close(client.Send)
+
+	metrics.DecrementWebSocketConnections()
+	metrics.ObserveWebSocketClientDroppedMessages(client.dropped.Load())
+	log.Printf("Client %s disconnected (delivered: %d, dropped: %d)", client.ID, client.delivered.Load(), client.dropped.Load())
+}
+
+// addUserClient indexes a client under its user, relaying the user's
+// channel while they have local connections. Runs on the hub goroutine.
+func (h *Hub) addUserClient(client *Client) {
+	clients, ok := h.users[client.UserID]
+	if !ok {
+		clients = make(map[*Client]bool)
+		h.users[client.UserID] = clients
+		if h.relay != nil {
+			h.relay.trackUser(client.UserID.String(), true)
+		}
+	}
+	clients[client] = true
+}
+
+// removeUserClient undoes addUserClient. Runs on the hub goroutine.
+func (h *Hub) removeUserClient(client *Client) {
+	clients, ok := h.users[client.UserID]
+	if !ok {
+		return
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.users, client.UserID)
+		if h.relay != nil {
+			h.relay.trackUser(client.UserID.String(), false)
+		}
+	}
+}
+
+// addSubscription subscribes a client to a post and announces the user's
+// presence if this is their first connection to it. It reports whether a new
+// subscription was made. Runs on the hub goroutine.
+func (h *Hub) addSubscription(client *Client, postID string) bool {
+	if _, ok := h.clients[client]; !ok || client.posts[postID] {
+		return false
+	}
+
+	subscribers, exists := h.posts[postID]
+	if !exists {
+		subscribers = make(map[*Client]bool)
+		h.posts[postID] = subscribers
+		if h.relay != nil {
+			h.relay.track(postID, true)
+		}
+	}
+
+	userPresent := h.userSubscribed(postID, client.UserID)
+	subscribers[client] = true
+	client.posts[postID] = true
+
+	if h.presence != nil {
+		h.presence.track(client, postID, true)
+	}
+	if !userPresent {
+		h.raise(&Message{Type: FramePresence, PostID: postID, UserID: client.UserID.String(), Content: gin.H{"status": "joined"}})
+	}
+	return true
+}
+
+// removeSubscription drops a single post subscription and announces the
+// user's departure if it was their last connection to it. Runs on the hub goroutine.
+func (h *Hub) removeSubscription(client *Client, postID string) {
+	if !client.posts[postID] {
+		return
+	}
+
+	delete(client.posts, postID)
+	delete(client.replaying, postID)
+	if subscribers, ok := h.posts[postID]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.posts, postID)
+			if h.relay != nil {
+				h.relay.track(postID, false)
+			}
+		}
+	}
+
+	if h.presence != nil {
+		h.presence.track(client, postID, false)
+	}
+	if !h.userSubscribed(postID, client.UserID) {
+		h.raise(&Message{Type: FramePresence, PostID: postID, UserID: client.UserID.String(), Content: gin.H{"status": "left"}})
+	}
+}
+
+// userSubscribed reports whether any local client of the user is subscribed to a post
+func (h *Hub) userSubscribed(postID string, userID uuid.UUID) bool {
+	for client := range h.posts[postID] {
+		if client.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTyping relays a typing indicator to a post the client is subscribed
+// to. Runs on the hub goroutine.
+func (h *Hub) handleTyping(req *typingRequest) {
+	client := req.client
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	if !client.posts[req.postID] {
+		h.deliverFrame(client, &Message{
+			Type:  FrameError,
+			ID:    req.id,
+			Error: &ProtocolError{Code: ErrCodeNotSubscribed, Message: "Subscribe to the post before sending typing events"},
+		})
+		return
+	}
+
+	h.raise(&Message{Type: FrameTyping, PostID: req.postID, UserID: client.UserID.String()})
+	if req.id != "" {
+		h.deliverFrame(client, &Message{Type: FrameAck, ID: req.id, PostID: req.postID})
+	}
+}
+
+// raise queues an event for the subscribers of its post. Events are emitted
+// once the current hub operation has finished, so delivering them (which may
+// disconnect slow consumers) never interleaves with the operation itself.
+func (h *Hub) raise(msg *Message) {
+	h.pending = append(h.pending, msg)
+}
+
+// flushPending emits raised events to local subscribers and relays them to
+// other instances. Runs on the hub goroutine.
+func (h *Hub) flushPending() {
+	for len(h.pending) > 0 {
+		msg := h.pending[0]
+		h.pending = h.pending[1:]
+
+		data, err := encodeFrame(msg)
+		if err != nil {
+			log.Printf("Error marshaling event: %v", err)
+			continue
+		}
+
+		metrics.IncrementWebSocketMessages(msg.Type)
+		for client := range h.posts[msg.PostID] {
+			h.deliver(client, data)
+		}
+
+		// Publishing is network I/O, so keep it off the hub goroutine
+		if h.relay != nil {
+			h.relay.queueEvent(msg.PostID, data)
+		}
+	}
+	h.pending = nil
+}
+
+// deliverFrame encodes and queues a frame for a single client. Runs on the hub goroutine.
+func (h *Hub) deliverFrame(client *Client, msg *Message) {
+	data, err := encodeFrame(msg)
+	if err != nil {
+		log.Printf("Error marshaling reply: %v", err)
+		return
+	}
+	h.deliver(client, data)
+}
+
+func (h *Hub) BroadcastToPost(ctx context.Context, postID string, message interface{}) {
+	h.BroadcastEvent(ctx, "new_message", postID, message)
+}
+
+// BroadcastEvent sends an event of the given type to every subscriber of a post
+func (h *Hub) BroadcastEvent(ctx context.Context, eventType, postID string, content interface{}) {
+	data, err := encodeFrame(&Message{
+		Type:    eventType,
 		PostID:  postID,
-		Content: message,
+		Content: content,
+	})
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
 	}
 
-	data, err := json.Marshal(msg)
+	// The event describes a stored change, so deliver it even if the
+	// caller's request is cancelled meanwhile
+	ctx = context.WithoutCancel(ctx)
+
+	// Sequence the event and keep it for clients that reconnect
+	var seq int64
+	if h.redisService != nil {
+		if seq, err = h.redisService.AppendPostEvent(ctx, postID, data, replayBufferSize); err != nil {
+			log.Printf("Error sequencing event for post %s: %v", postID, err)
+			seq = 0
+		} else {
+			data = stampSeq(data, seq)
+		}
+	}
+
+	metrics.IncrementWebSocketMessages(eventType)
+	h.broadcast <- &postMessage{postID: postID, seq: seq, data: data}
+
+	// Let other instances deliver to their own subscribers
+	if h.relay != nil {
+		h.relay.publish(ctx, postID, seq, data)
+	}
+}
+
+// SendToUsers sends an event to every connection of the given users, on this
+// and other instances. Used for private events such as direct messages.
+func (h *Hub) SendToUsers(ctx context.Context, userIDs []uuid.UUID, eventType string, content interface{}) {
+	data, err := encodeFrame(&Message{Type: eventType, Content: content})
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
 		return
 	}
 
-	h.broadcast <- data
+	// Like BroadcastEvent, deliver even if the caller's request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	metrics.IncrementWebSocketMessages(eventType)
+	for _, userID := range userIDs {
+		h.toUser <- &userMessage{userID: userID, data: data}
+		if h.relay != nil {
+			h.relay.publishToUser(ctx, userID.String(), data)
+		}
+	}
 }
 
+// HandleWebSocket upgrades the connection and authenticates it with a token from
+// the query string, the Sec-WebSocket-Protocol header or an initial auth frame.
+// Browsers cannot set an Authorization header on WebSocket handshakes.
 func (h *Hub) HandleWebSocket(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	token, echoProtocol := tokenFromRequest(c.Request)
+
+	var responseHeader http.Header
+	if echoProtocol {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {authSubprotocol}}
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, responseHeader)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
-	// Extract user ID from JWT token (set by middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		conn.Close()
+	go h.accept(conn, token)
+}
+
+// accept authenticates an upgraded connection and starts its pumps
+func (h *Hub) accept(conn *websocket.Conn, token string) {
+	userID, ok := h.authenticateConn(conn, token)
+	if !ok {
 		return
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	client := &Client{
-		ID:     uuid.New(),
-		UserID: userID.(uuid.UUID),
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		Hub:    h,
+		ID:         uuid.New(),
+		UserID:     userID,
+		Conn:       conn,
+		Send:       make(chan []byte, h.cfg.SendBufferSize),
+		Hub:        h,
+		ctx:        ctx,
+		cancel:     cancel,
+		posts:      make(map[string]bool),
+		lastTyping: make(map[string]time.Time),
 	}
 
 	client.Hub.register <- client
@@ -126,35 +583,118 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 	go client.readPump()
 }
 
+// readPump reads frames until the connection fails. The read deadline is
+// extended by each pong, so a peer that stops answering pings is dropped
+// after PongWait.
 func (c *Client) readPump() {
 	defer func() {
+		c.cancel()
 		c.Hub.unregister <- c
 		c.Conn.Close()
 	}()
 
+	cfg := c.Hub.cfg
+	c.Conn.SetReadLimit(cfg.MaxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	})
+
 	for {
-		_, _, err := c.Conn.ReadMessage()
+		_, data, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
+			c.recordClose(readCloseReason(err))
 			break
 		}
+
+		c.handleMessage(data)
+	}
+}
+
+// readCloseReason classifies the error that ended a read loop
+func readCloseReason(err error) string {
+	var netErr net.Error
+	switch {
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived):
+		return closeReasonClient
+	case errors.Is(err, websocket.ErrReadLimit):
+		return closeReasonTooLarge
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return closeReasonPongTimeout
+	default:
+		return closeReasonReadError
 	}
 }
 
+// recordClose counts the disconnect under the first reason reported for it
+func (c *Client) recordClose(reason string) {
+	c.closeOnce.Do(func() {
+		metrics.IncrementWebSocketDisconnects(reason)
+	})
+}
+
+// writePump sends queued messages and periodic pings. Every write is bounded
+// by WriteWait so a stalled peer cannot block the pump, and messages queued
+// while a write is in flight are flushed together in a single frame,
+// separated by newlines.
 func (c *Client) writePump() {
-	defer c.Conn.Close()
+	cfg := c.Hub.cfg
+	ticker := time.NewTicker(cfg.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
 
 	for {
 		select {
 		case message, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if !ok {
+				// The hub closed the channel
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
-			c.Conn.WriteMessage(websocket.TextMessage, message)
+			if err := c.writeBatch(message); err != nil {
+				c.recordClose(closeReasonWriteError)
+				return
+			}
+
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.recordClose(closeReasonWriteError)
+				return
+			}
 		}
 	}
 }
+
+// writeBatch writes message and everything already queued behind it as one frame
+func (c *Client) writeBatch(message []byte) error {
+	w, err := c.Conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+
+	w.Write(message)
+	// The hub may discard queued messages under the drop_oldest policy, so
+	// only take what is still there
+	for i, n := 0, len(c.Send); i < n; i++ {
+		select {
+		case queued, ok := <-c.Send:
+			if !ok {
+				return w.Close()
+			}
+			w.Write(frameSeparator)
+			w.Write(queued)
+		default:
+			return w.Close()
+		}
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,331 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"social-media-app/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func testConfig(policy string) config.WebSocketConfig {
+	return config.WebSocketConfig{
+		PingInterval:       50 * time.Millisecond,
+		PongWait:           200 * time.Millisecond,
+		WriteWait:          100 * time.Millisecond,
+		AuthTimeout:        time.Second,
+		MaxMessageSize:     4096,
+		SendBufferSize:     4,
+		SlowConsumerPolicy: policy,
+	}
+}
+
+func allowAll(context.Context, string) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+// waitForClients polls until the hub reports want clients or the deadline passes
+func waitForClients(t *testing.T, h *Hub, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := h.ClientCount()
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hub has %d clients, want %d", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// subscribe subscribes a client and consumes its own presence announcement
+func subscribe(h *Hub, client *Client, postID string) {
+	h.subscribe <- &subscription{client: client, postID: postID}
+	<-client.Send
+}
+
+func TestHubRemovesClientOnce(t *testing.T) {
+	h := NewHub(nil, nil, allowAll, nil, testConfig(PolicyDisconnect))
+	go h.Run()
+
+	client := &Client{
+		ID:    uuid.New(),
+		Send:  make(chan []byte, 1),
+		Hub:   h,
+		posts: make(map[string]bool),
+	}
+	postID := uuid.New().String()
+
+	h.register <- client
+	subscribe(h, client, postID)
+
+	// Fill the queue so the next broadcast trips the slow-consumer policy,
+	// then race the pump-initiated unregister against it
+	h.BroadcastEvent(context.Background(), "new_message", postID, "first")
+	h.BroadcastEvent(context.Background(), "new_message", postID, "second")
+	h.unregister <- client
+	h.unregister <- client
+
+	waitForClients(t, h, 0)
+
+	for range client.Send {
+	}
+	if client.dropped.Load() != 1 {
+		t.Fatalf("dropped = %d, want 1", client.dropped.Load())
+	}
+}
+
+func TestHubSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		policy        string
+		wantConnected bool
+		wantLast      string
+	}{
+		{PolicyDropOldest, true, "4"},
+		{PolicyDropNewest, true, "2"},
+		{PolicyDisconnect, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := testConfig(tt.policy)
+			cfg.SendBufferSize = 2
+			h := NewHub(nil, nil, allowAll, nil, cfg)
+			go h.Run()
+
+			client := &Client{
+				ID:    uuid.New(),
+				Send:  make(chan []byte, cfg.SendBufferSize),
+				Hub:   h,
+				posts: make(map[string]bool),
+			}
+			postID := uuid.New().String()
+			h.register <- client
+			subscribe(h, client, postID)
+
+			for _, content := range []string{"1", "2", "3", "4"} {
+				h.BroadcastEvent(context.Background(), "new_message", postID, content)
+			}
+
+			waitForClients(t, h, map[bool]int{true: 1, false: 0}[tt.wantConnected])
+			if client.dropped.Load() == 0 {
+				t.Fatal("expected dropped messages")
+			}
+			if !tt.wantConnected {
+				return
+			}
+
+			var last Message
+			for i := 0; i < cfg.SendBufferSize; i++ {
+				json.Unmarshal(<-client.Send, &last)
+			}
+			if last.Content != tt.wantLast {
+				t.Fatalf("last queued message = %v, want %s", last.Content, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestHubSendToUsers(t *testing.T) {
+	h := NewHub(nil, nil, allowAll, nil, testConfig(PolicyDisconnect))
+	go h.Run()
+
+	recipient, bystander := uuid.New(), uuid.New()
+	var clients []*Client
+	for _, userID := range []uuid.UUID{recipient, recipient, bystander} {
+		client := &Client{
+			ID:     uuid.New(),
+			UserID: userID,
+			Send:   make(chan []byte, 1),
+			Hub:    h,
+			posts:  make(map[string]bool),
+		}
+		h.register <- client
+		clients = append(clients, client)
+	}
+
+	// Every connection of the recipient gets the event, other users none
+	h.SendToUsers(context.Background(), []uuid.UUID{recipient}, "direct_message", "hello")
+	for _, client := range clients[:2] {
+		var msg Message
+		json.Unmarshal(<-client.Send, &msg)
+		if msg.Type != "direct_message" || msg.Content != "hello" {
+			t.Fatalf("got %+v, want direct_message hello", msg)
+		}
+	}
+
+	// Unregistered connections stop receiving; the bystander stays untouched
+	h.unregister <- clients[0]
+	h.SendToUsers(context.Background(), []uuid.UUID{recipient}, "direct_message", "again")
+	<-clients[1].Send
+	waitForClients(t, h, 2)
+	if len(clients[2].Send) != 0 {
+		t.Fatal("bystander received a direct message")
+	}
+}
+
+// TestHubStress runs connect, disconnect and broadcast storms concurrently
+// over real sockets. Run with -race.
+func TestHubStress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, policy := range []string{PolicyDropOldest, PolicyDropNewest, PolicyDisconnect} {
+		t.Run(policy, func(t *testing.T) {
+			h := NewHub(nil, nil, allowAll, nil, testConfig(policy))
+			go h.Run()
+
+			r := gin.New()
+			r.GET("/ws", h.HandleWebSocket)
+			server := httptest.NewServer(r)
+			defer server.Close()
+			url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?token=test"
+
+			postIDs := []string{uuid.New().String(), uuid.New().String()}
+
+			stop := make(chan struct{})
+			var broadcasters sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				broadcasters.Add(1)
+				go func(i int) {
+					defer broadcasters.Done()
+					for n := 0; ; n++ {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						h.BroadcastEvent(context.Background(), "new_message", postIDs[(i+n)%len(postIDs)], n)
+					}
+				}(i)
+			}
+
+			var clients sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				clients.Add(1)
+				go func(i int) {
+					defer clients.Done()
+					for n := 0; n < 5; n++ {
+						conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+						if err != nil {
+							t.Errorf("dial: %v", err)
+							return
+						}
+
+						for _, postID := range postIDs {
+							conn.WriteJSON(ClientMessage{Type: "subscribe", PostID: postID})
+						}
+
+						// Odd clients never read and become slow consumers
+						if i%2 == 0 {
+							conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+							for {
+								if _, _, err := conn.ReadMessage(); err != nil {
+									break
+								}
+							}
+						} else {
+							time.Sleep(20 * time.Millisecond)
+						}
+
+						if n%2 == 0 {
+							conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+						}
+						conn.Close()
+					}
+				}(i)
+			}
+
+			clients.Wait()
+			close(stop)
+			broadcasters.Wait()
+
+			waitForClients(t, h, 0)
+		})
+	}
+}
+
+// The hub records relay and presence changes and relayed events without
+// waiting for their consumers, which do Redis I/O. Only the latest state of
+// each change is kept; events keep their order.
+func TestTrackingNeverBlocks(t *testing.T) {
+	r := &relay{pending: make(map[string]bool), wake: make(chan struct{}, 1), outboxWake: make(chan struct{}, 1)}
+	p := &presence{pending: make(map[presenceKey]presenceOp), wake: make(chan struct{}, 1)}
+	client := &Client{ID: uuid.New(), UserID: uuid.New()}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5000; i++ {
+			r.track("post", i%2 == 0)
+			r.trackUser(fmt.Sprintf("user-%d", i), true)
+			p.track(client, "post", i%2 == 0)
+			r.queueEvent("post", []byte(fmt.Sprint(i)))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tracking blocked without a consumer")
+	}
+
+	if subscribe := r.pending[postChannel("post")]; subscribe {
+		t.Error("post subscription not coalesced to the latest change")
+	}
+	if len(r.pending) != 5001 {
+		t.Errorf("got %d pending relay changes, want 5001", len(r.pending))
+	}
+	if len(p.pending) != 1 || p.pending[presenceKey{postID: "post", connectionID: client.ID.String()}].join {
+		t.Errorf("presence not coalesced to the latest leave: %+v", p.pending)
+	}
+	if len(r.outbox) != relayOutboxSize {
+		t.Fatalf("got %d queued events, want %d", len(r.outbox), relayOutboxSize)
+	}
+	for i, envelope := range r.outbox {
+		if want := fmt.Sprint(5000 - relayOutboxSize + i); string(envelope.Data) != want {
+			t.Fatalf("queued event %d is %s, want %s", i, envelope.Data, want)
+		}
+	}
+}
+
+func TestAuthFrameSizeLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHub(nil, nil, allowAll, nil, testConfig(PolicyDisconnect))
+	go h.Run()
+
+	r := gin.New()
+	r.GET("/ws", h.HandleWebSocket)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	token := strings.Repeat("x", int(testConfig(PolicyDisconnect).MaxMessageSize))
+	if err := conn.WriteJSON(ClientMessage{Type: FrameAuth, Token: token}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("oversized auth frame: got %v, want close %d", err, websocket.CloseMessageTooBig)
+	}
+	if n := h.ClientCount(); n != 0 {
+		t.Fatalf("hub has %d clients", n)
+	}
+}
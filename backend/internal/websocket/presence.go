@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"social-media-app/internal/service"
+)
+
+const (
+	// presenceTTL is how long a connection stays present without a heartbeat
+	presenceTTL = 90 * time.Second
+	// presenceHeartbeat is how often live connections are refreshed in Redis
+	presenceHeartbeat = 30 * time.Second
+	// typingThrottle is the minimum interval between typing events a client
+	// may send for a post; clients should expire indicators after about twice this
+	typingThrottle = 2 * time.Second
+)
+
+type presenceOp struct {
+	join         bool
+	postID       string
+	userID       string
+	connectionID string
+}
+
+// presenceKey identifies one connection viewing one post
+type presenceKey struct {
+	postID       string
+	connectionID string
+}
+
+// presence mirrors the hub's subscriptions into Redis so every instance can
+// see who is viewing a post. It owns its own copy of the subscriptions and
+// refreshes them on a heartbeat, keeping Redis I/O off the hub goroutine.
+type presence struct {
+	redisService *service.RedisService
+
+	// Joins and leaves not yet applied. The hub records them without waiting
+	// on Redis; run keeps only the latest op of each connection and post.
+	mu      sync.Mutex
+	pending map[presenceKey]presenceOp
+	wake    chan struct{}
+
+	// postID -> connection ID -> user ID (owned by run)
+	posts map[string]map[string]string
+}
+
+func newPresence(redisService *service.RedisService) *presence {
+	return &presence{
+		redisService: redisService,
+		pending:      make(map[presenceKey]presenceOp),
+		wake:         make(chan struct{}, 1),
+		posts:        make(map[string]map[string]string),
+	}
+}
+
+func (p *presence) run() {
+	ctx := context.Background()
+	ticker := time.NewTicker(presenceHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.wake:
+			p.mu.Lock()
+			ops := p.pending
+			p.pending = make(map[presenceKey]presenceOp)
+			p.mu.Unlock()
+
+			for _, op := range ops {
+				p.apply(ctx, op)
+			}
+		case <-ticker.C:
+			for postID, connections := range p.posts {
+				if err := p.redisService.RefreshPresence(ctx, postID, connections, presenceTTL); err != nil {
+					log.Printf("Error refreshing presence for post %s: %v", postID, err)
+				}
+			}
+		}
+	}
+}
+
+func (p *presence) apply(ctx context.Context, op presenceOp) {
+	if op.join {
+		connections, ok := p.posts[op.postID]
+		if !ok {
+			connections = make(map[string]string)
+			p.posts[op.postID] = connections
+		}
+		connections[op.connectionID] = op.userID
+
+		single := map[string]string{op.connectionID: op.userID}
+		if err := p.redisService.RefreshPresence(ctx, op.postID, single, presenceTTL); err != nil {
+			log.Printf("Error recording presence for post %s: %v", op.postID, err)
+		}
+		return
+	}
+
+	if connections, ok := p.posts[op.postID]; ok {
+		delete(connections, op.connectionID)
+		if len(connections) == 0 {
+			delete(p.posts, op.postID)
+		}
+	}
+	if err := p.redisService.RemovePresence(ctx, op.postID, op.userID, op.connectionID); err != nil {
+		log.Printf("Error removing presence for post %s: %v", op.postID, err)
+	}
+}
+
+// track is called by the hub when a client subscribes to or leaves a post.
+// It never blocks: an op that supersedes one still pending replaces it.
+func (p *presence) track(client *Client, postID string, join bool) {
+	op := presenceOp{
+		join:         join,
+		postID:       postID,
+		userID:       client.UserID.String(),
+		connectionID: client.ID.String(),
+	}
+	key := presenceKey{postID: op.postID, connectionID: op.connectionID}
+
+	p.mu.Lock()
+	p.pending[key] = op
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"social-media-app/internal/model"
+	"social-media-app/internal/service"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// ProtocolVersion is the version of the frame format. Every server frame
+// carries it in "v"; clients may omit it, which means the current version.
+const ProtocolVersion = 1
+
+// Frame types
+const (
+	FrameAuth        = "auth"
+	FrameSubscribe   = "subscribe"
+	FrameUnsubscribe = "unsubscribe"
+	FrameSendMessage = "send_message"
+	FrameTyping      = "typing"
+	FramePing        = "ping"
+	FramePong        = "pong"
+	FrameAck         = "ack"
+	FrameError       = "error"
+	FramePresence    = "presence"
+	FrameResync      = "resync"
+)
+
+// Error codes carried in error frames
+const (
+	ErrCodeInvalidFrame       = "invalid_frame"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeInvalidPostID      = "invalid_post_id"
+	ErrCodePostNotFound       = "post_not_found"
+	ErrCodeNotSubscribed      = "not_subscribed"
+	ErrCodeInvalidMessage     = "invalid_message"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeUnavailable        = "unavailable"
+	ErrCodeInternal           = "internal_error"
+)
+
+// maxClientIDLength bounds the client-generated frame ID echoed in replies
+const maxClientIDLength = 64
+
+// Message is a frame sent by the server: an event, or an ack, error or pong
+// replying to the client frame with the same ID
+type Message struct {
+	V       int            `json:"v"`
+	Seq     int64          `json:"seq,omitempty"` // position in the post's event stream, see stampSeq
+	Type    string         `json:"type"`
+	ID      string         `json:"id,omitempty"`
+	PostID  string         `json:"post_id,omitempty"`
+	UserID  string         `json:"user_id,omitempty"`
+	Content interface{}    `json:"content,omitempty"`
+	Error   *ProtocolError `json:"error,omitempty"`
+}
+
+// ProtocolError describes why a client frame was rejected
+type ProtocolError struct {
+	Code       string  `json:"code"`
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after,omitempty"` // seconds, for rate_limited
+}
+
+// ClientMessage is a frame sent by the client over the socket. ID is chosen
+// by the client and echoed in the reply so requests can be correlated.
+type ClientMessage struct {
+	V       int    `json:"v,omitempty"`
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	PostID  string `json:"post_id,omitempty"`
+	Message string `json:"message,omitempty"`
+	Token   string `json:"token,omitempty"`
+	// LastSeq resumes a subscription after the last event the client saw
+	LastSeq *int64 `json:"last_seq,omitempty"`
+}
+
+// RateLimit reports whether a user may send another message and, if not,
+// how long until they may
+type RateLimit func(userID uuid.UUID) (bool, time.Duration)
+
+// handleMessage processes a frame from the client. Every rejected frame is
+// answered with an error frame; successful requests carrying an ID are acked.
+func (c *Client) handleMessage(data []byte) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.replyError("", &ProtocolError{Code: ErrCodeInvalidFrame, Message: "frame is not valid JSON"})
+		return
+	}
+
+	if msg.V != 0 && msg.V != ProtocolVersion {
+		c.replyError(msg.ID, &ProtocolError{
+			Code:    ErrCodeUnsupportedVersion,
+			Message: fmt.Sprintf("protocol version %d is not supported (current: %d)", msg.V, ProtocolVersion),
+		})
+		return
+	}
+
+	if len(msg.ID) > maxClientIDLength {
+		c.replyError("", &ProtocolError{Code: ErrCodeInvalidFrame, Message: fmt.Sprintf("id must be at most %d characters", maxClientIDLength)})
+		return
+	}
+
+	switch msg.Type {
+	case FrameSubscribe, FrameUnsubscribe:
+		c.handleSubscription(&msg)
+	case FrameSendMessage:
+		c.handleSendMessage(&msg)
+	case FrameTyping:
+		c.handleTyping(&msg)
+	case FramePing:
+		c.reply(Message{Type: FramePong, ID: msg.ID})
+	default:
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeUnknownType, Message: fmt.Sprintf("unknown frame type %q", msg.Type)})
+	}
+}
+
+func (c *Client) handleSubscription(msg *ClientMessage) {
+	if _, err := uuid.Parse(msg.PostID); err != nil {
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeInvalidPostID, Message: "Invalid post ID"})
+		return
+	}
+
+	sub := &subscription{client: c, postID: msg.PostID, lastSeq: msg.LastSeq}
+	if msg.Type == FrameSubscribe {
+		c.Hub.subscribe <- sub
+	} else {
+		c.Hub.unsubscribe <- sub
+	}
+
+	if msg.ID != "" {
+		c.reply(Message{Type: FrameAck, ID: msg.ID, PostID: msg.PostID})
+	}
+}
+
+// handleSendMessage stores a message sent over the socket, broadcasts it to
+// the post's subscribers and acks it with the stored message
+func (c *Client) handleSendMessage(msg *ClientMessage) {
+	if msg.ID == "" {
+		c.replyError("", &ProtocolError{Code: ErrCodeInvalidFrame, Message: "send_message requires an id"})
+		return
+	}
+
+	postID, err := uuid.Parse(msg.PostID)
+	if err != nil {
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeInvalidPostID, Message: "Invalid post ID"})
+		return
+	}
+
+	// Same validation as the HTTP endpoint
+	req := model.CreateMessageRequest{Message: msg.Message}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeInvalidMessage, Message: err.Error()})
+		return
+	}
+
+	if c.Hub.messages == nil {
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeUnavailable, Message: "Sending messages over WebSocket is not enabled"})
+		return
+	}
+
+	if c.Hub.allowMessage != nil {
+		if allowed, retryAfter := c.Hub.allowMessage(c.UserID); !allowed {
+			c.replyError(msg.ID, &ProtocolError{
+				Code:       ErrCodeRateLimited,
+				Message:    "Rate limit exceeded",
+				RetryAfter: retryAfter.Seconds(),
+			})
+			return
+		}
+	}
+
+	message, err := c.Hub.messages.CreateMessage(c.ctx, postID, c.UserID, &req)
+	if errors.Is(err, service.ErrPostNotFound) {
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodePostNotFound, Message: "Post not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create message from client %s: %v", c.ID, err)
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeInternal, Message: "Failed to send message"})
+		return
+	}
+
+	c.Hub.BroadcastToPost(c.ctx, msg.PostID, message)
+	c.reply(Message{Type: FrameAck, ID: msg.ID, PostID: msg.PostID, Content: message})
+}
+
+// handleTyping forwards a typing indicator to the hub, dropping indicators
+// sent more often than typingThrottle for the same post
+func (c *Client) handleTyping(msg *ClientMessage) {
+	if _, err := uuid.Parse(msg.PostID); err != nil {
+		c.replyError(msg.ID, &ProtocolError{Code: ErrCodeInvalidPostID, Message: "Invalid post ID"})
+		return
+	}
+
+	now := time.Now()
+	if last, ok := c.lastTyping[msg.PostID]; ok && now.Sub(last) < typingThrottle {
+		if msg.ID != "" {
+			c.reply(Message{Type: FrameAck, ID: msg.ID, PostID: msg.PostID})
+		}
+		return
+	}
+	c.lastTyping[msg.PostID] = now
+
+	c.Hub.typing <- &typingRequest{client: c, postID: msg.PostID, id: msg.ID}
+}
+
+func (c *Client) replyError(id string, frameErr *ProtocolError) {
+	c.reply(Message{Type: FrameError, ID: id, Error: frameErr})
+}
+
+// reply sends a frame to this client only. It goes through the hub, which
+// owns the send queue.
+func (c *Client) reply(msg Message) {
+	data, err := encodeFrame(&msg)
+	if err != nil {
+		log.Printf("Error marshaling reply: %v", err)
+		return
+	}
+
+	c.Hub.direct <- &directMessage{client: c, data: data}
+}
+
+// encodeFrame stamps a server frame with the protocol version and marshals it
+func encodeFrame(msg *Message) ([]byte, error) {
+	msg.V = ProtocolVersion
+	return json.Marshal(msg)
+}
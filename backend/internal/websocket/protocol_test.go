@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestHandleMessageRejectsBadFrames(t *testing.T) {
+	h := NewHub(nil, nil, allowAll, nil, testConfig(PolicyDisconnect))
+	go h.Run()
+
+	client := &Client{
+		ID:         uuid.New(),
+		Send:       make(chan []byte, 8),
+		Hub:        h,
+		posts:      make(map[string]bool),
+		lastTyping: make(map[string]time.Time),
+	}
+	h.register <- client
+	subscribed := uuid.NewString()
+
+	tests := []struct {
+		frame    string
+		wantType string
+		wantCode string
+	}{
+		{`not json`, FrameError, ErrCodeInvalidFrame},
+		{`{"v":2,"type":"ping","id":"1"}`, FrameError, ErrCodeUnsupportedVersion},
+		{`{"type":"shout","id":"1"}`, FrameError, ErrCodeUnknownType},
+		{`{"type":"subscribe","id":"1","post_id":"nope"}`, FrameError, ErrCodeInvalidPostID},
+		{`{"type":"send_message","post_id":"` + uuid.NewString() + `","message":"hi"}`, FrameError, ErrCodeInvalidFrame},
+		{`{"type":"send_message","id":"1","post_id":"` + uuid.NewString() + `","message":""}`, FrameError, ErrCodeInvalidMessage},
+		{`{"type":"send_message","id":"1","post_id":"` + uuid.NewString() + `","message":"hi"}`, FrameError, ErrCodeUnavailable},
+		{`{"v":1,"type":"ping","id":"1"}`, FramePong, ""},
+		{`{"type":"typing","id":"1","post_id":"` + uuid.NewString() + `"}`, FrameError, ErrCodeNotSubscribed},
+		{`{"type":"subscribe","id":"1","post_id":"` + subscribed + `"}`, FrameAck, ""},
+		{`{"type":"typing","post_id":"` + subscribed + `"}`, FrameTyping, ""},
+		{`{"type":"typing","id":"2","post_id":"` + subscribed + `"}`, FrameAck, ""}, // throttled
+	}
+
+	for _, tt := range tests {
+		client.handleMessage([]byte(tt.frame))
+
+		// Subscribing also announces our own presence
+		var reply Message
+		for reply.Type == "" || reply.Type == FramePresence {
+			if err := json.Unmarshal(<-client.Send, &reply); err != nil {
+				t.Fatalf("%s: invalid reply: %v", tt.frame, err)
+			}
+		}
+		if reply.V != ProtocolVersion || reply.Type != tt.wantType {
+			t.Fatalf("%s: got v%d %q, want v%d %q", tt.frame, reply.V, reply.Type, ProtocolVersion, tt.wantType)
+		}
+		if tt.wantCode != "" && (reply.Error == nil || reply.Error.Code != tt.wantCode) {
+			t.Fatalf("%s: got error %+v, want code %q", tt.frame, reply.Error, tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"social-media-app/internal/metrics"
+	"social-media-app/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// relayOutboxSize bounds the hub events waiting to be published. When Redis
+// falls this far behind the oldest are dropped; they are typing and presence
+// events, which are stale by then anyway.
+const relayOutboxSize = 1024
+
+// relayEnvelope wraps a hub payload published to other instances. It is
+// addressed to either a post's subscribers or a user's connections.
+type relayEnvelope struct {
+	Origin string          `json:"origin"`
+	PostID string          `json:"post_id,omitempty"`
+	UserID string          `json:"user_id,omitempty"`
+	Seq    int64           `json:"seq,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// relay fans hub messages out to other backend instances over Redis pub/sub.
+// Each instance only subscribes to the channels of posts it has local
+// subscribers for and of users with local connections.
+type relay struct {
+	instanceID   string
+	redisService *service.RedisService
+	pubsub       *redis.PubSub
+
+	// Subscription changes not yet applied, channel -> subscribe. The hub
+	// records them without waiting on Redis; applyOps keeps only the latest
+	// state of each channel.
+	mu      sync.Mutex
+	pending map[string]bool
+	wake    chan struct{}
+
+	// Hub events not yet published, oldest first. publishEvents sends them one
+	// at a time, so other instances receive them in the order they were raised.
+	outbox     []relayEnvelope
+	outboxWake chan struct{}
+}
+
+func newRelay(redisService *service.RedisService) *relay {
+	instanceID := uuid.New().String()
+
+	return &relay{
+		instanceID:   instanceID,
+		redisService: redisService,
+		// Start with an instance channel so the connection is always in subscribed mode
+		pubsub:     redisService.Subscribe(context.Background(), instanceChannel(instanceID)),
+		pending:    make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+		outboxWake: make(chan struct{}, 1),
+	}
+}
+
+func postChannel(postID string) string {
+	return fmt.Sprintf("ws:post:%s", postID)
+}
+
+func userChannel(userID string) string {
+	return fmt.Sprintf("ws:user:%s", userID)
+}
+
+func instanceChannel(instanceID string) string {
+	return fmt.Sprintf("ws:instance:%s", instanceID)
+}
+
+// run relays messages published by other instances to local subscribers
+func (r *relay) run(h *Hub) {
+	go r.applyOps()
+	go r.publishEvents()
+
+	for msg := range r.pubsub.Channel() {
+		var envelope relayEnvelope
+		if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+			log.Printf("Error unmarshaling relayed message: %v", err)
+			continue
+		}
+
+		// Our own publishes were already delivered locally
+		if envelope.Origin == r.instanceID {
+			continue
+		}
+
+		if envelope.UserID != "" {
+			userID, err := uuid.Parse(envelope.UserID)
+			if err != nil {
+				continue
+			}
+			h.toUser <- &userMessage{userID: userID, data: envelope.Data}
+			continue
+		}
+
+		h.broadcast <- &postMessage{postID: envelope.PostID, seq: envelope.Seq, data: envelope.Data}
+	}
+}
+
+// applyOps serializes channel subscription changes so they reach Redis in order
+func (r *relay) applyOps() {
+	ctx := context.Background()
+
+	for range r.wake {
+		r.mu.Lock()
+		ops := r.pending
+		r.pending = make(map[string]bool)
+		r.mu.Unlock()
+
+		for channel, subscribe := range ops {
+			var err error
+			if subscribe {
+				err = r.pubsub.Subscribe(ctx, channel)
+			} else {
+				err = r.pubsub.Unsubscribe(ctx, channel)
+			}
+			if err != nil {
+				log.Printf("Error updating relay subscription for %s: %v", channel, err)
+			}
+		}
+	}
+}
+
+// queue records a subscription change and never blocks the hub. A change
+// that supersedes one still pending replaces it.
+func (r *relay) queue(channel string, subscribe bool) {
+	r.mu.Lock()
+	r.pending[channel] = subscribe
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// track is called by the hub when a post gains its first or loses its last local subscriber
+func (r *relay) track(postID string, subscribe bool) {
+	r.queue(postChannel(postID), subscribe)
+}
+
+// trackUser is called by the hub when a user gains their first or loses their last local connection
+func (r *relay) trackUser(userID string, subscribe bool) {
+	r.queue(userChannel(userID), subscribe)
+}
+
+func (r *relay) publishToUser(ctx context.Context, userID string, data []byte) {
+	envelope := relayEnvelope{
+		Origin: r.instanceID,
+		UserID: userID,
+		Data:   data,
+	}
+
+	if err := r.redisService.Publish(ctx, userChannel(userID), envelope); err != nil {
+		log.Printf("Error publishing message for user %s: %v", userID, err)
+	}
+}
+
+// publishEvents publishes queued hub events in order
+func (r *relay) publishEvents() {
+	ctx := context.Background()
+
+	for range r.outboxWake {
+		r.mu.Lock()
+		envelopes := r.outbox
+		r.outbox = nil
+		r.mu.Unlock()
+
+		for _, envelope := range envelopes {
+			if err := r.redisService.Publish(ctx, postChannel(envelope.PostID), envelope); err != nil {
+				log.Printf("Error publishing event for post %s: %v", envelope.PostID, err)
+			}
+		}
+	}
+}
+
+// queueEvent records an unsequenced hub event for publishing and never blocks the hub
+func (r *relay) queueEvent(postID string, data []byte) {
+	r.mu.Lock()
+	if len(r.outbox) == relayOutboxSize {
+		r.outbox = r.outbox[1:]
+		metrics.IncrementWebSocketDroppedMessages("relay")
+	}
+	r.outbox = append(r.outbox, relayEnvelope{Origin: r.instanceID, PostID: postID, Data: data})
+	r.mu.Unlock()
+
+	select {
+	case r.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *relay) publish(ctx context.Context, postID string, seq int64, data []byte) {
+	envelope := relayEnvelope{
+		Origin: r.instanceID,
+		PostID: postID,
+		Seq:    seq,
+		Data:   data,
+	}
+
+	if err := r.redisService.Publish(ctx, postChannel(postID), envelope); err != nil {
+		log.Printf("Error publishing message for post %s: %v", postID, err)
+	}
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"log"
+	"strconv"
+
+	"social-media-app/internal/service"
+)
+
+// replayBufferSize is roughly how many events are kept per post for resuming
+// clients; a client further behind than this is told to refetch
+const replayBufferSize = 500
+
+// replayState buffers live events for a client while its missed events are
+// being fetched, so replayed and live events reach it in order. Unsequenced
+// events (presence, typing, or any event Redis failed to sequence) are held
+// back too, keeping their place among the sequenced ones.
+// Owned by the hub goroutine.
+type replayState struct {
+	live []*postMessage
+}
+
+// replayResult is the outcome of fetching a resuming client's missed events
+type replayResult struct {
+	client  *Client
+	postID  string
+	events  []service.PostEvent
+	current int64
+	resync  bool
+}
+
+// stampSeq adds a sequence number to an encoded event. Events are JSON
+// objects, so the field is spliced in after the opening brace.
+func stampSeq(data []byte, seq int64) []byte {
+	stamped := make([]byte, 0, len(data)+24)
+	stamped = append(stamped, `{"seq":`...)
+	stamped = strconv.AppendInt(stamped, seq, 10)
+	stamped = append(stamped, ',')
+	return append(stamped, data[1:]...)
+}
+
+// fetchReplay loads the events a client missed on a post since lastSeq and
+// hands them to the hub. Runs on its own goroutine.
+func (h *Hub) fetchReplay(client *Client, postID string, lastSeq int64) {
+	result := &replayResult{client: client, postID: postID}
+
+	current, events, err := h.redisService.GetPostEventsSince(client.ctx, postID, lastSeq, replayBufferSize)
+	switch {
+	case err != nil:
+		log.Printf("Error fetching replay for post %s: %v", postID, err)
+		result.resync = true
+	case lastSeq > current:
+		// The sequence was reset (the stream expired), so lastSeq is meaningless
+		result.resync = true
+	case current > lastSeq && (len(events) == 0 || events[0].Seq != lastSeq+1):
+		// The events right after lastSeq were already trimmed
+		result.resync = true
+	case len(events) > 0 && events[len(events)-1].Seq < current && len(events) == replayBufferSize:
+		result.resync = true
+	default:
+		result.events = events
+	}
+	result.current = current
+
+	h.replayDone <- result
+}
+
+// finishReplay delivers a client's missed events followed by the live events
+// buffered meanwhile, or a resync frame when the gap cannot be filled. Runs
+// on the hub goroutine.
+func (h *Hub) finishReplay(result *replayResult) {
+	client := result.client
+	state, ok := client.replaying[result.postID]
+	if _, connected := h.clients[client]; !connected || !ok {
+		return
+	}
+	delete(client.replaying, result.postID)
+
+	var lastSeq int64
+	if result.resync {
+		h.deliverFrame(client, &Message{
+			Type:    FrameResync,
+			PostID:  result.postID,
+			Content: map[string]int64{"seq": result.current},
+		})
+	} else {
+		for _, event := range result.events {
+			h.deliver(client, stampSeq(event.Data, event.Seq))
+			lastSeq = event.Seq
+		}
+	}
+
+	// Sequenced events the replay already covered are dropped; unsequenced
+	// ones are not in the replay buffer, so all of them are delivered
+	for _, message := range state.live {
+		if message.seq == 0 || message.seq > lastSeq {
+			h.deliver(client, message.data)
+		}
+	}
+}
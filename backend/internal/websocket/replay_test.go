@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"social-media-app/internal/service"
+
+	"github.com/google/uuid"
+)
+
+func TestStampSeq(t *testing.T) {
+	data, _ := encodeFrame(&Message{Type: "new_message", PostID: "p"})
+
+	var msg Message
+	if err := json.Unmarshal(stampSeq(data, 42), &msg); err != nil {
+		t.Fatalf("stamped frame is not valid JSON: %v", err)
+	}
+	if msg.Seq != 42 || msg.Type != "new_message" || msg.PostID != "p" {
+		t.Fatalf("got %+v", msg)
+	}
+}
+
+func TestFinishReplayOrdersReplayBeforeLive(t *testing.T) {
+	h := NewHub(nil, nil, allowAll, nil, testConfig(PolicyDisconnect))
+	go h.Run()
+
+	postID := uuid.NewString()
+	raw, _ := encodeFrame(&Message{Type: "new_message", PostID: postID})
+	event := func(seq int64) []byte {
+		return stampSeq(raw, seq)
+	}
+
+	client := &Client{
+		ID:        uuid.New(),
+		Send:      make(chan []byte, 8),
+		Hub:       h,
+		posts:     make(map[string]bool),
+		replaying: map[string]*replayState{postID: {}},
+	}
+	h.register <- client
+	subscribe(h, client, postID)
+
+	// Live events arriving mid-replay are held back, overlapping ones dropped,
+	// and unsequenced ones keep their place after the replay
+	h.broadcast <- &postMessage{postID: postID, seq: 2, data: event(2)}
+	h.broadcast <- &postMessage{postID: postID, data: raw}
+	h.broadcast <- &postMessage{postID: postID, seq: 3, data: event(3)}
+	h.replayDone <- &replayResult{
+		client:  client,
+		postID:  postID,
+		current: 2,
+		events:  []service.PostEvent{{Seq: 1, Data: raw}, {Seq: 2, Data: raw}},
+	}
+
+	for _, want := range []int64{1, 2, 0, 3} {
+		var msg Message
+		json.Unmarshal(<-client.Send, &msg)
+		if msg.Seq != want {
+			t.Fatalf("got seq %d, want %d", msg.Seq, want)
+		}
+	}
+}
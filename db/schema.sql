@@ -1,5 +1,6 @@
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 -- Users table
 CREATE TABLE users (
@@ -7,6 +8,8 @@ CREATE TABLE users (
     username VARCHAR(50) UNIQUE NOT NULL,
     email VARCHAR(100) UNIQUE NOT NULL,
     password_hash TEXT NOT NULL,
+    follower_count INTEGER NOT NULL DEFAULT 0,
+    following_count INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT NOW(),
     updated_at TIMESTAMP DEFAULT NOW()
 );
@@ -17,8 +20,11 @@ CREATE TABLE posts (
     user_id UUID REFERENCES users(id) ON DELETE CASCADE,
     image_url TEXT NOT NULL,
     caption TEXT,
+    entities JSONB,
+    search_vector TSVECTOR,
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
+    updated_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
 );
 
 -- Messages table
@@ -27,14 +33,126 @@ CREATE TABLE messages (
     post_id UUID REFERENCES posts(id) ON DELETE CASCADE,
     sender_id UUID REFERENCES users(id) ON DELETE CASCADE,
     message TEXT NOT NULL,
+    entities JSONB,
+    search_vector TSVECTOR,
+    created_at TIMESTAMP DEFAULT NOW(),
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Follows table
+CREATE TABLE follows (
+    follower_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+-- Reactions table (posts and messages)
+CREATE TABLE reactions (
+    target_type VARCHAR(16) NOT NULL,
+    target_id UUID NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (target_type, target_id, user_id, kind)
+);
+
+-- Conversations table (direct messages; direct_key is set for 1:1 conversations)
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    direct_key TEXT UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_message_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Conversation participants table
+CREATE TABLE conversation_participants (
+    conversation_id UUID REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id UUID,
+    last_read_message_at TIMESTAMP,
+    joined_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+-- Direct messages table
+CREATE TABLE direct_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT NOW()
 );
 
+-- Notifications table (repeated events collapse into one unread row per user and group_key)
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    group_key TEXT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    post_id UUID REFERENCES posts(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    actor_count INTEGER NOT NULL DEFAULT 1,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Distinct actors of each notification
+CREATE TABLE notification_actors (
+    notification_id UUID REFERENCES notifications(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (notification_id, actor_id)
+);
+
+-- Mentions and hashtags parsed from post captions and messages
+CREATE TABLE post_mentions (
+    post_id UUID REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE post_hashtags (
+    post_id UUID REFERENCES posts(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (post_id, tag)
+);
+
+CREATE TABLE message_mentions (
+    message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE TABLE message_hashtags (
+    message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (message_id, tag)
+);
+
 -- Indexes for better performance
 CREATE INDEX idx_posts_user_id ON posts(user_id);
 CREATE INDEX idx_posts_created_at ON posts(created_at DESC);
+CREATE INDEX idx_posts_created_at_id ON posts(created_at DESC, id DESC);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
 CREATE INDEX idx_messages_post_id ON messages(post_id);
 CREATE INDEX idx_messages_created_at ON messages(created_at);
+CREATE INDEX idx_messages_deleted_at ON messages(deleted_at);
+CREATE INDEX idx_follows_followee_id ON follows(followee_id);
+CREATE INDEX idx_conversations_last_message_at ON conversations(last_message_at DESC, id DESC);
+CREATE INDEX idx_conversation_participants_user_id ON conversation_participants(user_id);
+CREATE INDEX idx_direct_messages_conversation_created ON direct_messages(conversation_id, created_at);
+CREATE UNIQUE INDEX idx_notifications_unread_group ON notifications(user_id, group_key) WHERE read_at IS NULL;
+CREATE INDEX idx_notifications_user_updated ON notifications(user_id, updated_at);
+-- Search: documents are maintained by the backend's repository layer
+CREATE INDEX idx_posts_search ON posts USING GIN (search_vector);
+CREATE INDEX idx_messages_search ON messages USING GIN (search_vector);
+CREATE INDEX idx_users_username_trgm ON users USING GIN (username gin_trgm_ops);
+CREATE INDEX idx_post_mentions_user_id ON post_mentions(user_id);
+CREATE INDEX idx_post_hashtags_tag ON post_hashtags(tag);
+CREATE INDEX idx_message_mentions_user_id ON message_mentions(user_id);
+CREATE INDEX idx_message_hashtags_tag ON message_hashtags(tag);
 
 -- Sample data for testing
 INSERT INTO users (username, email, password_hash) VALUES 
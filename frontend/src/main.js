@@ -4,6 +4,7 @@ import { createRouter, createWebHistory } from 'vue-router'
 import axios from 'axios'
 import './style.css'
 import App from './App.vue'
+import { installAuthRefresh } from './services/http'
 import Home from './components/Home.vue'
 import Login from './components/Login.vue'
 
@@ -24,5 +25,11 @@ const pinia = createPinia()
 const app = createApp(App)
 
 app.use(pinia)
+
+// Refresh the access token once when a request is rejected as unauthorized
+installAuthRefresh(axios, async () => {
+  const { useAuthStore } = await import('./stores/auth')
+  return useAuthStore(pinia)
+})
 app.use(router)
 app.mount('#app')
\ No newline at end of file
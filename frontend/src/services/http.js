@@ -0,0 +1,22 @@
+// Retry a request rejected as unauthorized once, after refreshing the access
+// token. Every 401 awaits the same refresh (see the auth store), and a request
+// sent before another caller's refresh finished is simply retried with the
+// new token.
+export function installAuthRefresh(client, getAuthStore) {
+  client.interceptors.response.use(undefined, async (error) => {
+    const request = error.config
+    if (error.response?.status !== 401 || request._retried || request.url.includes('/users/refresh')) {
+      return Promise.reject(error)
+    }
+
+    const authStore = await getAuthStore()
+    const sentWith = request.headers['Authorization']
+    if (sentWith === `Bearer ${authStore.token}` && !(await authStore.refresh())) {
+      return Promise.reject(error)
+    }
+
+    request._retried = true
+    request.headers['Authorization'] = `Bearer ${authStore.token}`
+    return client(request)
+  })
+}
@@ -0,0 +1,87 @@
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import axios from 'axios'
+import { createPinia, setActivePinia } from 'pinia'
+import { installAuthRefresh } from './http'
+import { useAuthStore } from '../stores/auth'
+
+vi.stubGlobal('localStorage', {
+  store: {},
+  getItem(key) { return this.store[key] ?? null },
+  setItem(key, value) { this.store[key] = String(value) },
+  removeItem(key) { delete this.store[key] }
+})
+
+// A server that rotates refresh tokens and, like rotateSessionScript, revokes
+// the session when a refresh token is used twice
+function fakeServer() {
+  const server = { refreshCalls: 0, revoked: false, access: 'access-1', refresh: 'refresh-1' }
+  server.adapter = async (config) => {
+    const respond = (status, data) => {
+      const response = { status, data, headers: {}, config, statusText: String(status) }
+      if (status >= 400) {
+        throw new axios.AxiosError('Request failed', null, config, null, response)
+      }
+      return response
+    }
+
+    if (config.url.endsWith('/users/refresh')) {
+      server.refreshCalls++
+      await new Promise((resolve) => setTimeout(resolve, 10))
+      if (server.revoked || JSON.parse(config.data).refresh_token !== server.refresh) {
+        server.revoked = true
+        return respond(401, { error: 'Refresh token reused' })
+      }
+      server.access = `access-${server.refreshCalls + 1}`
+      server.refresh = `refresh-${server.refreshCalls + 1}`
+      return respond(200, { access_token: server.access, refresh_token: server.refresh })
+    }
+
+    if (server.revoked || config.headers['Authorization'] !== `Bearer ${server.access}`) {
+      return respond(401, { error: 'Token expired' })
+    }
+    return respond(200, { ok: true })
+  }
+  return server
+}
+
+describe('installAuthRefresh', () => {
+  let server
+  let authStore
+
+  beforeEach(() => {
+    setActivePinia(createPinia())
+    server = fakeServer()
+    axios.defaults.adapter = server.adapter
+    axios.interceptors.response.clear()
+    installAuthRefresh(axios, async () => useAuthStore())
+
+    authStore = useAuthStore()
+    authStore.setTokens('access-0', 'refresh-1')
+  })
+
+  it('shares one refresh between simultaneous 401s', async () => {
+    const [a, b] = await Promise.all([axios.get('/api/v1/posts'), axios.get('/api/v1/feed')])
+
+    expect(a.data.ok).toBe(true)
+    expect(b.data.ok).toBe(true)
+    expect(server.refreshCalls).toBe(1)
+    expect(server.revoked).toBe(false)
+    expect(authStore.token).toBe('access-2')
+  })
+
+  it('retries a request that raced a finished refresh without refreshing again', async () => {
+    await axios.get('/api/v1/posts')
+    const stale = await axios.get('/api/v1/feed', { headers: { Authorization: 'Bearer access-0' } })
+
+    expect(stale.data.ok).toBe(true)
+    expect(server.refreshCalls).toBe(1)
+  })
+
+  it('shares the refresh with a WebSocket reconnect', async () => {
+    const [request, reconnect] = await Promise.all([axios.get('/api/v1/posts'), authStore.refresh()])
+
+    expect(request.data.ok).toBe(true)
+    expect(reconnect).toBe(true)
+    expect(server.refreshCalls).toBe(1)
+  })
+})
@@ -7,6 +7,14 @@ class WebSocketService {
     this.maxReconnectAttempts = 5
     this.reconnectInterval = 1000
     this.messageHandlers = new Map()
+    this.subscriptions = new Set()
+    // Requests awaiting an ack or error, keyed by client-generated frame ID
+    this.pending = new Map()
+    this.nextRequestId = 1
+    // Last typing event sent per post; the server drops anything more frequent
+    this.lastTyping = new Map()
+    // Highest event sequence number seen per post, for resuming after a reconnect
+    this.lastSeq = new Map()
   }
 
   connect() {
@@ -25,19 +33,37 @@ class WebSocketService {
       this.ws.onopen = () => {
         console.log('WebSocket connected')
         this.reconnectAttempts = 0
+
+        // Restore post subscriptions after (re)connecting, replaying missed events
+        this.subscriptions.forEach(postId => {
+          this.send({ v: 1, type: 'subscribe', post_id: postId, ...this.resumeFrom(postId) })
+        })
       }
       
       this.ws.onmessage = (event) => {
         try {
-          const message = JSON.parse(event.data)
-          this.handleMessage(message)
+          // The server may batch several events into one frame, one per line
+          event.data.split('\n').forEach((line) => {
+            this.handleMessage(JSON.parse(line))
+          })
         } catch (error) {
           console.error('Error parsing WebSocket message:', error)
         }
       }
       
-      this.ws.onclose = () => {
-        console.log('WebSocket disconnected')
+      this.ws.onclose = async (event) => {
+        console.log('WebSocket disconnected', event.code, event.reason)
+
+        this.pending.forEach(({ reject }) => reject(new Error('WebSocket disconnected')))
+        this.pending.clear()
+
+        // 4001: invalid token, 4002: token expired (see backend websocket/auth.go)
+        if (event.code === 4001) {
+          return
+        }
+        if (event.code === 4002 && !(await authStore.refresh())) {
+          return
+        }
         this.attemptReconnect()
       }
       
@@ -70,13 +96,41 @@ class WebSocketService {
   }
 
   handleMessage(message) {
-    const { type, post_id, content } = message
+    const { type, id, post_id, content, seq } = message
+
+    if (seq) {
+      // Replayed and live events can overlap around a reconnect
+      if (seq <= (this.lastSeq.get(post_id) || 0)) {
+        return
+      }
+      this.lastSeq.set(post_id, seq)
+    }
+    if (type === 'resync') {
+      // Too far behind to replay; handlers refetch and we continue from here
+      this.lastSeq.set(post_id, content.seq)
+    }
+
+    // Replies to our own requests
+    if ((type === 'ack' || type === 'error') && id && this.pending.has(id)) {
+      const { resolve, reject } = this.pending.get(id)
+      this.pending.delete(id)
+      if (type === 'ack') {
+        resolve(content)
+      } else {
+        reject(Object.assign(new Error(message.error.message), message.error))
+      }
+      return
+    }
+    if (type === 'error') {
+      console.error('WebSocket protocol error:', message.error)
+      return
+    }
     
     // Call registered handlers for this message type
     const handlers = this.messageHandlers.get(type) || []
     handlers.forEach(handler => {
       try {
-        handler(content, post_id)
+        handler(content, post_id, message)
       } catch (error) {
         console.error('Error in message handler:', error)
       }
@@ -102,7 +156,60 @@ class WebSocketService {
     }
   }
 
-  // Send a message (if needed for future features)
+  resumeFrom(postId) {
+    return this.lastSeq.has(postId) ? { last_seq: this.lastSeq.get(postId) } : {}
+  }
+
+  // Receive realtime events for a post
+  subscribe(postId) {
+    this.subscriptions.add(postId)
+    if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+      this.send({ v: 1, type: 'subscribe', post_id: postId, ...this.resumeFrom(postId) })
+    }
+  }
+
+  // Stop receiving realtime events for a post
+  unsubscribe(postId) {
+    this.subscriptions.delete(postId)
+    this.lastSeq.delete(postId)
+    if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+      this.send({ type: 'unsubscribe', post_id: postId })
+    }
+  }
+
+  isConnected() {
+    return this.ws !== null && this.ws.readyState === WebSocket.OPEN
+  }
+
+  // Send a frame and wait for its ack; rejects with the server's error
+  request(frame) {
+    if (!this.isConnected()) {
+      return Promise.reject(new Error('WebSocket is not connected'))
+    }
+
+    const id = String(this.nextRequestId++)
+    return new Promise((resolve, reject) => {
+      this.pending.set(id, { resolve, reject })
+      this.send({ v: 1, ...frame, id })
+    })
+  }
+
+  // Post a comment over the socket; resolves with the stored message
+  sendMessage(postId, message) {
+    return this.request({ type: 'send_message', post_id: postId, message })
+  }
+
+  // Tell other viewers of a post that we are typing (throttled to every 2s)
+  sendTyping(postId) {
+    const now = Date.now()
+    if (!this.isConnected() || now - (this.lastTyping.get(postId) || 0) < 2000) {
+      return
+    }
+    this.lastTyping.set(postId, now)
+    this.send({ v: 1, type: 'typing', post_id: postId })
+  }
+
+  // Send a raw frame
   send(message) {
     if (this.ws && this.ws.readyState === WebSocket.OPEN) {
       this.ws.send(JSON.stringify(message))
@@ -1,10 +1,16 @@
 import { defineStore } from 'pinia'
 import axios from 'axios'
 
+// The refresh in flight, shared by every caller. The server treats a second
+// use of the same refresh token as theft and revokes the session, so parallel
+// 401s and WebSocket reconnects must never refresh on their own.
+let refreshing = null
+
 export const useAuthStore = defineStore('auth', {
   state: () => ({
     user: null,
     token: localStorage.getItem('token') || null,
+    refreshToken: localStorage.getItem('refreshToken') || null,
     isAuthenticated: false
   }),
 
@@ -12,17 +18,12 @@ export const useAuthStore = defineStore('auth', {
     async login(credentials) {
       try {
         const response = await axios.post('/api/v1/users/login', credentials)
-        const { token, user } = response.data
+        const { access_token, refresh_token, user } = response.data
         
-        this.token = token
+        this.setTokens(access_token, refresh_token)
         this.user = user
         this.isAuthenticated = true
         
-        localStorage.setItem('token', token)
-        
-        // Set default authorization header
-        axios.defaults.headers.common['Authorization'] = `Bearer ${token}`
-        
         // Connect to WebSocket after successful login
         const { websocketService } = await import('../services/websocket')
         websocketService.connect()
@@ -48,12 +49,61 @@ export const useAuthStore = defineStore('auth', {
       }
     },
 
+    setTokens(accessToken, refreshToken) {
+      this.token = accessToken
+      this.refreshToken = refreshToken
+
+      localStorage.setItem('token', accessToken)
+      localStorage.setItem('refreshToken', refreshToken)
+
+      // Set default authorization header
+      axios.defaults.headers.common['Authorization'] = `Bearer ${accessToken}`
+    },
+
+    // Exchange the refresh token for a new token pair. Concurrent callers
+    // share one request and its result.
+    refresh() {
+      if (!refreshing) {
+        refreshing = this.rotateTokens().finally(() => {
+          refreshing = null
+        })
+      }
+      return refreshing
+    },
+
+    async rotateTokens() {
+      if (!this.refreshToken) {
+        return false
+      }
+
+      try {
+        const response = await axios.post('/api/v1/users/refresh', {
+          refresh_token: this.refreshToken
+        })
+        this.setTokens(response.data.access_token, response.data.refresh_token)
+        return true
+      } catch (error) {
+        return false
+      }
+    },
+
     async logout() {
+      // Revoke the session server-side (best effort)
+      if (this.isAuthenticated) {
+        try {
+          await axios.post('/api/v1/users/logout')
+        } catch (error) {
+          // Session may already be gone
+        }
+      }
+
       this.user = null
       this.token = null
+      this.refreshToken = null
       this.isAuthenticated = false
       
       localStorage.removeItem('token')
+      localStorage.removeItem('refreshToken')
       delete axios.defaults.headers.common['Authorization']
       
       // Disconnect WebSocket